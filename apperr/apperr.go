@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
 	"strings"
@@ -16,6 +17,7 @@ import (
 	"github.com/aws/smithy-go"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 )
@@ -27,9 +29,11 @@ const (
 	ErrMsgResourceNotFound = "The resource does not exist"
 	ErrMsgUnauthorized     = "Unauthorized access"
 	ErrMsgForbidden        = "Access forbidden"
+	ErrMsgTooManyRequests  = "Too many requests, please try again later"
 
 	ErrMsgConflictOnCreation = "There is a conflict on the creation of the resource"
 	ErrMsgInternalError      = "Something went wrong, please try again later"
+	ErrMsgServiceUnavailable = "The service is temporarily unavailable, please try again later"
 )
 
 // AppError represents a structured application error
@@ -219,6 +223,17 @@ func ConflictWithDetails(resourceType, conflictingField string) *AppError {
 	}
 }
 
+// ConflictWithCode creates a conflict error with a specific error code
+func ConflictWithCode(code, message, details string) *AppError {
+	return &AppError{
+		Code:       code,
+		Message:    message,
+		HTTPStatus: http.StatusConflict,
+		Details:    details,
+		Timestamp:  time.Now().UTC(),
+	}
+}
+
 func ConflictWithFields(msg string, fields any) *AppError {
 	data, err := json.Marshal(fields)
 	if err != nil {
@@ -234,6 +249,20 @@ func ConflictWithFields(msg string, fields any) *AppError {
 	}
 }
 
+// TooManyRequests creates a rate limit error
+// retryAfter is rounded up to whole seconds and exposed in Fields
+func TooManyRequests(retryAfter time.Duration) *AppError {
+	return &AppError{
+		Code:       "RATE_LIMIT_EXCEEDED",
+		Message:    ErrMsgTooManyRequests,
+		HTTPStatus: http.StatusTooManyRequests,
+		Fields: map[string]any{
+			"retry_after": int64(math.Ceil(retryAfter.Seconds())),
+		},
+		Timestamp: time.Now().UTC(),
+	}
+}
+
 // BadRequest creates a bad request error with details
 // Accepts string or fmt.Stringer for details
 func BadRequest(detail any) *AppError {
@@ -294,6 +323,18 @@ func Internal(original error) *AppError {
 	}
 }
 
+// ServiceUnavailable creates a 503 error for failures of a dependency that
+// are expected to be transient
+func ServiceUnavailable(code string, original error) *AppError {
+	return &AppError{
+		Code:        code,
+		Message:     ErrMsgServiceUnavailable,
+		HTTPStatus:  http.StatusServiceUnavailable,
+		OriginalErr: original,
+		Timestamp:   time.Now().UTC(),
+	}
+}
+
 // Wrap wraps an existing error with additional context
 // It attempts to map common error types to appropriate AppError instances
 func Wrap(err error) *AppError {
@@ -387,78 +428,25 @@ func Wrap(err error) *AppError {
 	// Handle PostgreSQL errors (lib/pq)
 	var pqErr *pq.Error
 	if errors.As(err, &pqErr) {
-		switch pqErr.Code {
-		case "23505": // Unique constraint violation
-			constraint := pqErr.Constraint
-			if constraint != "" {
-				return ConflictWithDetails("resource", constraint)
-			}
-			return Conflict()
-		case "23502": // Not null constraint violation
-			column := pqErr.Column
-			if column != "" {
-				return BadRequestWithCode(
-					"REQUIRED_FIELD_MISSING",
-					"Required field is missing",
-					fmt.Sprintf("Field '%s' is required but was not provided", column),
-				)
-			}
-			return BadRequest("One or more required fields are missing")
-		case "23503": // Foreign key violation
-			return BadRequestWithCode(
-				"INVALID_REFERENCE",
-				"The request references a resource that does not exist",
-				pqErr.Message,
-			)
-		case "23514": // Check constraint violation
-			return BadRequestWithCode(
-				"CONSTRAINT_VIOLATION",
-				"The provided data violates a validation constraint",
-				pqErr.Message,
-			)
-		case "08003": // Connection does not exist
-			return BadRequestWithCode(
-				"DATABASE_CONNECTION_ERROR",
-				"Database connection error",
-				"Unable to establish database connection",
-			)
-		case "08006": // Connection failure
-			return BadRequestWithCode(
-				"DATABASE_CONNECTION_FAILED",
-				"Database connection failed",
-				"Lost connection to database",
-			)
-		case "42P01": // Undefined table
-			return Internal(pqErr)
-		case "42P07": // Duplicate table
-			return Conflict()
-		default:
-			return Internal(pqErr)
-		}
-	}
-
-	// Handle pgx errors (pgx/v5) - pgx uses pgconn.PgError
-	// Check for pgx connection errors and constraint violations via error message
-	errStr := err.Error()
-	if strings.Contains(errStr, "duplicate key value") || strings.Contains(errStr, "unique constraint") {
-		return Conflict()
-	}
-	if strings.Contains(errStr, "violates not-null constraint") || strings.Contains(errStr, "null value in column") {
-		return BadRequest("One or more required fields are missing")
-	}
-	if strings.Contains(errStr, "violates foreign key constraint") {
-		return BadRequestWithCode(
-			"INVALID_REFERENCE",
-			"The request references a resource that does not exist",
-			errStr,
-		)
-	}
-	if strings.Contains(errStr, "violates check constraint") {
-		return BadRequestWithCode(
-			"CONSTRAINT_VIOLATION",
-			"The provided data violates a validation constraint",
-			errStr,
-		)
+		return fromPostgres(postgresFailure{
+			code:       string(pqErr.Code),
+			message:    pqErr.Message,
+			constraint: pqErr.Constraint,
+			column:     pqErr.Column,
+			table:      pqErr.Table,
+		}, pqErr)
+	}
+
+	// Handle PostgreSQL errors (pgx/v5)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return fromPostgres(postgresFailure{
+			code:       pgErr.Code,
+			message:    pgErr.Message,
+			constraint: pgErr.ConstraintName,
+			column:     pgErr.ColumnName,
+			table:      pgErr.TableName,
+		}, pgErr)
 	}
 
 	// Handle SQL no rows error
@@ -466,6 +454,12 @@ func Wrap(err error) *AppError {
 		return NotFound()
 	}
 
+	// Handle cache misses and other errors reporting NotFound
+	var notFound interface{ NotFound() bool }
+	if errors.As(err, &notFound) && notFound.NotFound() {
+		return NotFound()
+	}
+
 	// Handle Redis errors
 	if errors.Is(err, redis.Nil) {
 		return NotFound()
@@ -0,0 +1,95 @@
+package apperr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// postgresFailure holds the fields shared by lib/pq and pgx errors.
+type postgresFailure struct {
+	code       string
+	message    string
+	constraint string
+	column     string
+	table      string
+}
+
+// fields returns the non-empty constraint, column and table names.
+func (f postgresFailure) fields() map[string]any {
+	fields := map[string]any{}
+	if f.constraint != "" {
+		fields["constraint"] = f.constraint
+	}
+	if f.column != "" {
+		fields["column"] = f.column
+	}
+	if f.table != "" {
+		fields["table"] = f.table
+	}
+	return fields
+}
+
+// fromPostgres maps a Postgres error by SQLSTATE, first by exact code and
+// then by class
+func fromPostgres(f postgresFailure, original error) *AppError {
+	var e *AppError
+	switch f.code {
+	case "23505": // Unique constraint violation
+		if f.constraint != "" {
+			e = ConflictWithDetails("resource", f.constraint)
+		} else {
+			e = Conflict()
+		}
+	case "23502": // Not null constraint violation
+		if f.column != "" {
+			e = BadRequestWithCode(
+				"REQUIRED_FIELD_MISSING",
+				"Required field is missing",
+				fmt.Sprintf("Field '%s' is required but was not provided", f.column),
+			)
+		} else {
+			e = BadRequest("One or more required fields are missing")
+		}
+	case "23503": // Foreign key violation
+		e = BadRequestWithCode(
+			"INVALID_REFERENCE",
+			"The request references a resource that does not exist",
+			f.message,
+		)
+	case "23P01": // Exclusion constraint violation
+		e = ConflictWithCode("RESOURCE_CONFLICT", "The resource conflicts with an existing one", f.message)
+	case "40001", "40P01": // Serialization failure, deadlock
+		e = ServiceUnavailable("TRANSACTION_CONFLICT", original)
+	case "57014": // Query canceled, usually by statement_timeout
+		e = ServiceUnavailable("DATABASE_TIMEOUT", original)
+	case "53300": // Too many connections
+		e = ServiceUnavailable("DATABASE_BUSY", original)
+	case "42P07": // Duplicate table
+		e = Conflict()
+	}
+	if e != nil {
+		return e.WithFields(f.fields())
+	}
+
+	switch {
+	case strings.HasPrefix(f.code, "23"): // Integrity constraint violation
+		e = BadRequestWithCode(
+			"CONSTRAINT_VIOLATION",
+			"The provided data violates a validation constraint",
+			f.message,
+		)
+	case strings.HasPrefix(f.code, "22"): // Data exception
+		e = BadRequestWithCode(
+			"INVALID_DATA",
+			"The provided data is not valid for the field",
+			f.message,
+		)
+	case strings.HasPrefix(f.code, "08"): // Connection exception
+		e = ServiceUnavailable("DATABASE_UNAVAILABLE", original)
+	case strings.HasPrefix(f.code, "53"), strings.HasPrefix(f.code, "57P"): // Insufficient resources, shutdown
+		e = ServiceUnavailable("DATABASE_UNAVAILABLE", original)
+	default:
+		return Internal(original)
+	}
+	return e.WithFields(f.fields())
+}
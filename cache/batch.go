@@ -0,0 +1,389 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const scanCount = 500
+
+func tagKey(tag string) string {
+	return "tag:{" + tag + "}"
+}
+
+// globEscaper quotes the characters SCAN MATCH treats as patterns.
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+func (m *LocalCache) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if value, err := m.get(key, now); err == nil {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+func (m *LocalCache) MSet(ctx context.Context, values map[string]any, expiration time.Duration) error {
+	entries := make(map[string]*entry, len(values))
+	for key, value := range values {
+		e, err := m.newEntry(key, value, expiration, nil)
+		if err != nil {
+			return err
+		}
+		entries[key] = e
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, e := range entries {
+		m.add(key, e)
+	}
+	return nil
+}
+
+func (m *LocalCache) DelByPrefix(ctx context.Context, prefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range m.lru.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			m.lru.Remove(key)
+		}
+	}
+	return nil
+}
+
+func (m *LocalCache) SetWithTags(ctx context.Context, key string, value any, expiration time.Duration, tags ...string) error {
+	e, err := m.newEntry(key, value, expiration, tags)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.add(key, e)
+	return nil
+}
+
+func (m *LocalCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tag := range tags {
+		// Removing a key edits the tag set through onEvict.
+		for key := range m.tags[tag] {
+			m.lru.Remove(key)
+		}
+	}
+	return nil
+}
+
+func (c *RedisClient) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	values, _, err := c.getWithTTL(ctx, false, keys...)
+	return values, err
+}
+
+// getWithTTL pipelines a GET, and optionally a PTTL, for every key.
+// Keys are fetched one by one rather than through MGET so the same
+// pipeline works when keys hash to different cluster slots.
+func (c *RedisClient) getWithTTL(ctx context.Context, withTTL bool, keys ...string) (map[string]string, map[string]time.Duration, error) {
+	values := make(map[string]string, len(keys))
+	expirations := make(map[string]time.Duration, len(keys))
+	if len(keys) == 0 {
+		return values, expirations, nil
+	}
+
+	gets := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	_, err := c.conn.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, key := range keys {
+			gets[i] = p.Get(ctx, key)
+			if withTTL {
+				ttls[i] = p.PTTL(ctx, key)
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, nil, err
+	}
+
+	for i, key := range keys {
+		value, err := gets[i].Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		values[key] = value
+		if withTTL {
+			// PTTL reports negative durations for keys without an expiry.
+			expirations[key] = max(ttls[i].Val(), 0)
+		}
+	}
+	return values, expirations, nil
+}
+
+func (c *RedisClient) MSet(ctx context.Context, values map[string]any, expiration time.Duration) error {
+	encoded := make(map[string][]byte, len(values))
+	for key, value := range values {
+		data, err := encode(value)
+		if err != nil {
+			return err
+		}
+		encoded[key] = data
+	}
+	_, err := c.conn.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for key, data := range encoded {
+			p.Set(ctx, key, data, expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+// DelByPrefix walks the keyspace with SCAN so Redis is never blocked by KEYS.
+func (c *RedisClient) DelByPrefix(ctx context.Context, prefix string) error {
+	match := globEscaper.Replace(prefix) + "*"
+	// SCAN only walks the node it is sent to, so clusters are scanned master by master.
+	if cluster, ok := c.conn.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return c.scanAndUnlink(ctx, node, match)
+		})
+	}
+	return c.scanAndUnlink(ctx, c.conn, match)
+}
+
+func (c *RedisClient) scanAndUnlink(ctx context.Context, node redis.Cmdable, match string) error {
+	iter := node.Scan(ctx, 0, match, scanCount).Iterator()
+	batch := make([]string, 0, scanCount)
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == scanCount {
+			if err := c.unlink(ctx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return c.unlink(ctx, batch)
+}
+
+// unlink pipelines one UNLINK per key so keys may live in different slots.
+func (c *RedisClient) unlink(ctx context.Context, keys []string) error {
+	_, err := c.conn.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, key := range keys {
+			p.Unlink(ctx, key)
+		}
+		return nil
+	})
+	return err
+}
+
+// tagScript adds a member to a tag set and keeps the set alive at least as
+// long as its longest lived member, a zero ttl makes the set persistent.
+var tagScript = redis.NewScript(`
+local existed = redis.call("EXISTS", KEYS[1])
+redis.call("SADD", KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl <= 0 then
+	redis.call("PERSIST", KEYS[1])
+elseif existed == 0 then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+else
+	local current = redis.call("PTTL", KEYS[1])
+	if current >= 0 and current < ttl then
+		redis.call("PEXPIRE", KEYS[1], ttl)
+	end
+end
+return 1
+`)
+
+func (c *RedisClient) SetWithTags(ctx context.Context, key string, value any, expiration time.Duration, tags ...string) error {
+	data, err := encode(value)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, key, data, expiration)
+		for _, tag := range tags {
+			tagScript.Eval(ctx, p, []string{tagKey(tag)}, key, expiration.Milliseconds())
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *RedisClient) InvalidateTags(ctx context.Context, tags ...string) error {
+	_, err := c.invalidateTags(ctx, tags...)
+	return err
+}
+
+// invalidateTags deletes the members of each tag and returns them. Members
+// are walked with SSCAN and removed from the set before their keys are
+// unlinked, so a key re-tagged in between keeps its tag and at worst the set
+// holds a member whose key is already gone.
+func (c *RedisClient) invalidateTags(ctx context.Context, tags ...string) ([]string, error) {
+	var deleted []string
+	for _, tag := range tags {
+		iter := c.conn.SScan(ctx, tagKey(tag), 0, "", scanCount).Iterator()
+		batch := make([]string, 0, scanCount)
+		for iter.Next(ctx) {
+			batch = append(batch, iter.Val())
+			if len(batch) == scanCount {
+				if err := c.untagAndUnlink(ctx, tag, batch); err != nil {
+					return deleted, err
+				}
+				deleted = append(deleted, batch...)
+				batch = batch[:0]
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return deleted, err
+		}
+		if len(batch) == 0 {
+			continue
+		}
+		if err := c.untagAndUnlink(ctx, tag, batch); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, batch...)
+	}
+	return deleted, nil
+}
+
+func (c *RedisClient) untagAndUnlink(ctx context.Context, tag string, members []string) error {
+	srem := make([]any, len(members))
+	for i, member := range members {
+		srem[i] = member
+	}
+	if err := c.conn.SRem(ctx, tagKey(tag), srem...).Err(); err != nil {
+		return err
+	}
+	return c.unlink(ctx, members)
+}
+
+func (c *TieredCache) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	values, _ := c.local.MGet(ctx, keys...)
+	var missing []string
+	for _, key := range keys {
+		if _, ok := values[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	remote, ttls, err := c.remote.getWithTTL(ctx, true, missing...)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range remote {
+		values[key] = value
+		c.local.Set(ctx, key, value, c.boundTTL(ttls[key]))
+	}
+	return values, nil
+}
+
+func (c *TieredCache) MSet(ctx context.Context, values map[string]any, expiration time.Duration) error {
+	if err := c.remote.MSet(ctx, values, expiration); err != nil {
+		return err
+	}
+	if err := c.local.MSet(ctx, values, c.boundTTL(expiration)); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	return c.publish(ctx, invalidation{Keys: keys})
+}
+
+func (c *TieredCache) DelByPrefix(ctx context.Context, prefix string) error {
+	c.local.DelByPrefix(ctx, prefix)
+	if err := c.remote.DelByPrefix(ctx, prefix); err != nil {
+		return err
+	}
+	return c.publish(ctx, invalidation{Prefix: prefix})
+}
+
+// SetWithTags only records tags in Redis, L1 copies on other replicas are
+// reached by broadcasting the keys that InvalidateTags removes.
+func (c *TieredCache) SetWithTags(ctx context.Context, key string, value any, expiration time.Duration, tags ...string) error {
+	if err := c.remote.SetWithTags(ctx, key, value, expiration, tags...); err != nil {
+		return err
+	}
+	if err := c.local.Set(ctx, key, value, c.boundTTL(expiration)); err != nil {
+		return err
+	}
+	return c.publish(ctx, invalidation{Keys: []string{key}})
+}
+
+func (c *TieredCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	keys, err := c.remote.invalidateTags(ctx, tags...)
+	for _, key := range keys {
+		c.local.Del(ctx, key)
+	}
+	if len(keys) > 0 {
+		if perr := c.publish(ctx, invalidation{Keys: keys}); err == nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+func (c *EncryptedCache) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	raw, err := c.Client.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		plaintext, err := c.open(ctx, key, []byte(value))
+		if err != nil {
+			return nil, err
+		}
+		values[key] = string(plaintext)
+	}
+	return values, nil
+}
+
+func (c *EncryptedCache) MSet(ctx context.Context, values map[string]any, expiration time.Duration) error {
+	sealed := make(map[string]any, len(values))
+	for key, value := range values {
+		plaintext, err := encode(value)
+		if err != nil {
+			return err
+		}
+		if sealed[key], err = c.seal(ctx, key, plaintext); err != nil {
+			return err
+		}
+	}
+	return c.Client.MSet(ctx, sealed, expiration)
+}
+
+func (c *EncryptedCache) SetWithTags(ctx context.Context, key string, value any, expiration time.Duration, tags ...string) error {
+	plaintext, err := encode(value)
+	if err != nil {
+		return err
+	}
+	sealed, err := c.seal(ctx, key, plaintext)
+	if err != nil {
+		return err
+	}
+	return c.Client.SetWithTags(ctx, key, sealed, expiration, tags...)
+}
@@ -0,0 +1,261 @@
+// Package cachetest holds a conformance suite that every
+// cache.Client implementation is expected to pass.
+package cachetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/clineomx/trussrod/cache"
+)
+
+// Factory returns a fresh client for a single subtest.
+// The suite closes the client once the subtest finishes.
+type Factory func(t *testing.T) cache.Client
+
+// Run executes the conformance suite against the clients built by newClient.
+func Run(t *testing.T, newClient Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, c cache.Client)
+	}{
+		{"Ping", testPing},
+		{"MissReturnsErrMiss", testMiss},
+		{"StringRoundTrip", testString},
+		{"BytesRoundTrip", testBytes},
+		{"StructRoundTrip", testStruct},
+		{"ScalarRoundTrip", testScalar},
+		{"Overwrite", testOverwrite},
+		{"Del", testDel},
+		{"DelMissingKey", testDelMissing},
+		{"Expiration", testExpiration},
+		{"NoExpiration", testNoExpiration},
+		{"MGetMSet", testMGetMSet},
+		{"MGetEmpty", testMGetEmpty},
+		{"DelByPrefix", testDelByPrefix},
+		{"InvalidateTags", testInvalidateTags},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newClient(t)
+			t.Cleanup(func() { c.Close() })
+			tt.fn(t, c)
+		})
+	}
+}
+
+// key namespaces keys per subtest so shared backends don't collide.
+func key(t *testing.T, suffix string) string {
+	return fmt.Sprintf("cachetest:%s:%d:%s", t.Name(), time.Now().UnixNano(), suffix)
+}
+
+func mustSet(t *testing.T, c cache.Client, k string, v any, ttl time.Duration) {
+	t.Helper()
+	if err := c.Set(context.Background(), k, v, ttl); err != nil {
+		t.Fatalf("Set(%q): %v", k, err)
+	}
+}
+
+func mustGet(t *testing.T, c cache.Client, k string) string {
+	t.Helper()
+	v, err := c.Get(context.Background(), k)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", k, err)
+	}
+	return v
+}
+
+func assertMiss(t *testing.T, c cache.Client, k string) {
+	t.Helper()
+	v, err := c.Get(context.Background(), k)
+	if !errors.Is(err, cache.ErrMiss) {
+		t.Fatalf("Get(%q) = %q, %v; want cache.ErrMiss", k, v, err)
+	}
+}
+
+func testPing(t *testing.T, c cache.Client) {
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
+
+func testMiss(t *testing.T, c cache.Client) {
+	assertMiss(t, c, key(t, "absent"))
+}
+
+func testString(t *testing.T, c cache.Client) {
+	k := key(t, "string")
+	mustSet(t, c, k, "hello", 0)
+	if got := mustGet(t, c, k); got != "hello" {
+		t.Fatalf("got %q, want %q", got, "hello")
+	}
+}
+
+func testBytes(t *testing.T, c cache.Client) {
+	k := key(t, "bytes")
+	in := []byte{0x00, 0xff, 'a', 0x10}
+	mustSet(t, c, k, in, 0)
+	in[0] = 0x01
+	if got := mustGet(t, c, k); got != string([]byte{0x00, 0xff, 'a', 0x10}) {
+		t.Fatalf("got %x, want 00ff6110", got)
+	}
+}
+
+type sample struct {
+	ID    string   `json:"id"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags"`
+}
+
+func testStruct(t *testing.T, c cache.Client) {
+	k := key(t, "struct")
+	in := sample{ID: "abc", Count: 3, Tags: []string{"x", "y"}}
+	mustSet(t, c, k, in, 0)
+
+	var out sample
+	if err := json.Unmarshal([]byte(mustGet(t, c, k)), &out); err != nil {
+		t.Fatalf("decoding stored struct: %v", err)
+	}
+	if out.ID != in.ID || out.Count != in.Count || len(out.Tags) != len(in.Tags) {
+		t.Fatalf("got %+v, want %+v", out, in)
+	}
+}
+
+func testScalar(t *testing.T, c cache.Client) {
+	cases := map[string]any{
+		"int":   42,
+		"float": 1.5,
+		"bool":  true,
+	}
+	want := map[string]string{
+		"int":   "42",
+		"float": "1.5",
+		"bool":  "true",
+	}
+	for name, v := range cases {
+		k := key(t, name)
+		mustSet(t, c, k, v, 0)
+		if got := mustGet(t, c, k); got != want[name] {
+			t.Fatalf("%s: got %q, want %q", name, got, want[name])
+		}
+	}
+}
+
+func testOverwrite(t *testing.T, c cache.Client) {
+	k := key(t, "overwrite")
+	mustSet(t, c, k, "first", 0)
+	mustSet(t, c, k, "second", 0)
+	if got := mustGet(t, c, k); got != "second" {
+		t.Fatalf("got %q, want %q", got, "second")
+	}
+}
+
+func testDel(t *testing.T, c cache.Client) {
+	k := key(t, "del")
+	mustSet(t, c, k, "value", 0)
+	if err := c.Del(context.Background(), k); err != nil {
+		t.Fatalf("Del: %v", err)
+	}
+	assertMiss(t, c, k)
+}
+
+func testDelMissing(t *testing.T, c cache.Client) {
+	if err := c.Del(context.Background(), key(t, "absent")); err != nil {
+		t.Fatalf("Del on a missing key: %v", err)
+	}
+}
+
+func testExpiration(t *testing.T, c cache.Client) {
+	k := key(t, "ttl")
+	mustSet(t, c, k, "short-lived", 50*time.Millisecond)
+	if got := mustGet(t, c, k); got != "short-lived" {
+		t.Fatalf("got %q before expiry", got)
+	}
+	time.Sleep(120 * time.Millisecond)
+	assertMiss(t, c, k)
+}
+
+func testNoExpiration(t *testing.T, c cache.Client) {
+	k := key(t, "forever")
+	mustSet(t, c, k, "kept", 0)
+	time.Sleep(20 * time.Millisecond)
+	if got := mustGet(t, c, k); got != "kept" {
+		t.Fatalf("got %q, want %q", got, "kept")
+	}
+}
+
+func testMGetMSet(t *testing.T, c cache.Client) {
+	a, b, missing := key(t, "a"), key(t, "b"), key(t, "missing")
+	err := c.MSet(context.Background(), map[string]any{a: "one", b: []byte("two")}, time.Minute)
+	if err != nil {
+		t.Fatalf("MSet: %v", err)
+	}
+	values, err := c.MGet(context.Background(), a, b, missing)
+	if err != nil {
+		t.Fatalf("MGet: %v", err)
+	}
+	if len(values) != 2 || values[a] != "one" || values[b] != "two" {
+		t.Fatalf("got %v, want the two stored keys", values)
+	}
+	if _, ok := values[missing]; ok {
+		t.Fatalf("missing key present in %v", values)
+	}
+}
+
+func testMGetEmpty(t *testing.T, c cache.Client) {
+	values, err := c.MGet(context.Background())
+	if err != nil {
+		t.Fatalf("MGet: %v", err)
+	}
+	if len(values) != 0 {
+		t.Fatalf("got %v, want no values", values)
+	}
+}
+
+func testDelByPrefix(t *testing.T, c cache.Client) {
+	// The prefix holds glob characters, an unescaped SCAN MATCH would also hit outside.
+	base := key(t, "")
+	prefix := base + "patient[1]*:"
+	inside, outside := prefix+"a", base+"patient1x:a"
+	mustSet(t, c, inside, "v", time.Minute)
+	mustSet(t, c, prefix+"b", "v", time.Minute)
+	mustSet(t, c, outside, "v", time.Minute)
+
+	if err := c.DelByPrefix(context.Background(), prefix); err != nil {
+		t.Fatalf("DelByPrefix: %v", err)
+	}
+	assertMiss(t, c, inside)
+	assertMiss(t, c, prefix+"b")
+	if got := mustGet(t, c, outside); got != "v" {
+		t.Fatalf("key outside the prefix was removed")
+	}
+}
+
+func testInvalidateTags(t *testing.T, c cache.Client) {
+	tag, other := key(t, "patient:1"), key(t, "patient:2")
+	a, b, untouched := key(t, "a"), key(t, "b"), key(t, "c")
+	ctx := context.Background()
+	if err := c.SetWithTags(ctx, a, "v", time.Minute, tag); err != nil {
+		t.Fatalf("SetWithTags: %v", err)
+	}
+	if err := c.SetWithTags(ctx, b, "v", 0, tag, other); err != nil {
+		t.Fatalf("SetWithTags: %v", err)
+	}
+	if err := c.SetWithTags(ctx, untouched, "v", time.Minute, other); err != nil {
+		t.Fatalf("SetWithTags: %v", err)
+	}
+
+	if err := c.InvalidateTags(ctx, tag); err != nil {
+		t.Fatalf("InvalidateTags: %v", err)
+	}
+	assertMiss(t, c, a)
+	assertMiss(t, c, b)
+	if got := mustGet(t, c, untouched); got != "v" {
+		t.Fatalf("key under another tag was removed")
+	}
+}
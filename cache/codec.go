@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// encode turns a value into the bytes every backend stores, so strings,
+// byte slices and structs read back the same regardless of the backend.
+// Strings and bytes are stored verbatim, scalars in their textual form,
+// BinaryMarshalers through MarshalBinary and anything else as JSON.
+func encode(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return []byte{}, nil
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return bytes.Clone(v), nil
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), nil
+	case uint:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(nil, v, 10), nil
+	case float32:
+		return strconv.AppendFloat(nil, float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'f', -1, 64), nil
+	case bool:
+		return strconv.AppendBool(nil, v), nil
+	case time.Time:
+		return v.AppendFormat(nil, time.RFC3339Nano), nil
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	default:
+		return json.Marshal(v)
+	}
+}
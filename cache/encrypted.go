@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/clineomx/trussrod/keys"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+const (
+	envelopeVersion = 1
+	// maxDEKUses keeps random GCM nonces far away from collision bounds.
+	maxDEKUses         = 1 << 24
+	defaultDEKLifetime = time.Hour
+	dekCacheSize       = 128
+)
+
+var errMalformedEnvelope = errors.New("cache: malformed encrypted value")
+
+// EncryptedCache encrypts values with AES-GCM before handing them to the
+// wrapped Client. Each value carries the KMS-wrapped data key it was sealed
+// with, so it can be opened by any replica. Data keys are reused for writes
+// until they age out, and unwrapped keys are remembered for reads.
+type EncryptedCache struct {
+	Client
+	manager  keys.Manager
+	lifetime time.Duration
+
+	mu      sync.Mutex
+	dek     cipher.AEAD
+	wrapped []byte
+	created time.Time
+	uses    int
+
+	deks *lru.Cache[string, cipher.AEAD]
+}
+
+// NewEncryptedCache wraps inner, lifetime defaults to an hour when zero.
+func NewEncryptedCache(inner Client, manager keys.Manager, lifetime time.Duration) (*EncryptedCache, error) {
+	if lifetime <= 0 {
+		lifetime = defaultDEKLifetime
+	}
+	deks, err := lru.New[string, cipher.AEAD](dekCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedCache{
+		Client:   inner,
+		manager:  manager,
+		lifetime: lifetime,
+		deks:     deks,
+	}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// currentDEK returns the data key used for writes, rotating it when due.
+func (c *EncryptedCache) currentDEK(ctx context.Context) (cipher.AEAD, []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dek == nil || time.Since(c.created) > c.lifetime || c.uses >= maxDEKUses {
+		plain, wrapped, err := c.manager.CreateDEK(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		aead, err := newAEAD(plain)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.dek, c.wrapped, c.created, c.uses = aead, wrapped, time.Now(), 0
+		c.deks.Add(string(wrapped), aead)
+	}
+	c.uses++
+	return c.dek, c.wrapped, nil
+}
+
+func (c *EncryptedCache) unwrapDEK(ctx context.Context, wrapped []byte) (cipher.AEAD, error) {
+	if aead, ok := c.deks.Get(string(wrapped)); ok {
+		return aead, nil
+	}
+	plain, err := c.manager.Decrypt(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(plain)
+	if err != nil {
+		return nil, err
+	}
+	c.deks.Add(string(wrapped), aead)
+	return aead, nil
+}
+
+// seal lays out version | wrapped key length | wrapped key | nonce | ciphertext.
+// The cache key is used as associated data so values can't be swapped between keys.
+func (c *EncryptedCache) seal(ctx context.Context, key string, plaintext []byte) ([]byte, error) {
+	aead, wrapped, err := c.currentDEK(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) > 0xffff {
+		return nil, fmt.Errorf("wrapped data key is too long")
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, 3+len(wrapped)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, envelopeVersion)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(wrapped)))
+	out = append(out, wrapped...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, []byte(key)), nil
+}
+
+func (c *EncryptedCache) open(ctx context.Context, key string, data []byte) ([]byte, error) {
+	if len(data) < 3 || data[0] != envelopeVersion {
+		return nil, errMalformedEnvelope
+	}
+	n := int(binary.BigEndian.Uint16(data[1:3]))
+	data = data[3:]
+	if len(data) < n {
+		return nil, errMalformedEnvelope
+	}
+	wrapped, data := data[:n], data[n:]
+
+	aead, err := c.unwrapDEK(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errMalformedEnvelope
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(key))
+}
+
+func (c *EncryptedCache) Get(ctx context.Context, key string) (string, error) {
+	raw, err := c.Client.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := c.open(ctx, key, []byte(raw))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func (c *EncryptedCache) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	plaintext, err := encode(value)
+	if err != nil {
+		return err
+	}
+	sealed, err := c.seal(ctx, key, plaintext)
+	if err != nil {
+		return err
+	}
+	return c.Client.Set(ctx, key, sealed, expiration)
+}
@@ -0,0 +1,142 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/clineomx/trussrod/cache"
+	"github.com/clineomx/trussrod/cache/cachetest"
+	"github.com/clineomx/trussrod/keys"
+)
+
+// fakeManager wraps data keys by prefixing them, standing in for KMS.
+type fakeManager struct{}
+
+var fakeWrapPrefix = []byte("wrapped:")
+
+func (fakeManager) CreateDEK(ctx context.Context) ([]byte, []byte, error) {
+	plain := make([]byte, 32)
+	if _, err := rand.Read(plain); err != nil {
+		return nil, nil, err
+	}
+	return plain, append(bytes.Clone(fakeWrapPrefix), plain...), nil
+}
+
+func (fakeManager) Decrypt(ctx context.Context, target []byte) ([]byte, error) {
+	plain, ok := bytes.CutPrefix(target, fakeWrapPrefix)
+	if !ok {
+		return nil, errors.New("unknown wrapped key")
+	}
+	return bytes.Clone(plain), nil
+}
+
+func (fakeManager) CreateSigner(key string) keys.Signer {
+	return nil
+}
+
+func newEncrypted(t *testing.T) (*cache.EncryptedCache, *cache.LocalCache) {
+	t.Helper()
+	inner, err := cache.NewLocalCacheWithOptions(&cache.LocalOptions{MaxEntries: 1024})
+	if err != nil {
+		t.Fatalf("NewLocalCacheWithOptions: %v", err)
+	}
+	c, err := cache.NewEncryptedCache(inner, fakeManager{}, 0)
+	if err != nil {
+		t.Fatalf("NewEncryptedCache: %v", err)
+	}
+	return c, inner
+}
+
+func TestEncryptedCacheConformance(t *testing.T) {
+	cachetest.Run(t, func(t *testing.T) cache.Client {
+		c, _ := newEncrypted(t)
+		return c
+	})
+}
+
+func TestEncryptedCacheStoresCiphertext(t *testing.T) {
+	ctx := context.Background()
+	c, inner := newEncrypted(t)
+	defer c.Close()
+
+	if err := c.Set(ctx, "patient:1", "secret diagnosis", time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	raw, err := inner.Get(ctx, "patient:1")
+	if err != nil {
+		t.Fatalf("inner Get: %v", err)
+	}
+	if bytes.Contains([]byte(raw), []byte("secret diagnosis")) {
+		t.Fatal("inner cache holds the plaintext")
+	}
+	got, err := c.Get(ctx, "patient:1")
+	if err != nil || got != "secret diagnosis" {
+		t.Fatalf("Get = %q, %v, want the plaintext", got, err)
+	}
+}
+
+func TestEncryptedCacheEmptyValue(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newEncrypted(t)
+	defer c.Close()
+
+	if err := c.Set(ctx, "empty", "", time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	got, err := c.Get(ctx, "empty")
+	if err != nil || got != "" {
+		t.Fatalf("Get = %q, %v, want an empty value", got, err)
+	}
+}
+
+func TestEncryptedCacheRejectsTampering(t *testing.T) {
+	ctx := context.Background()
+	c, inner := newEncrypted(t)
+	defer c.Close()
+
+	if err := c.Set(ctx, "patient:1", "secret", time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	raw, err := inner.Get(ctx, "patient:1")
+	if err != nil {
+		t.Fatalf("inner Get: %v", err)
+	}
+
+	flipped := []byte(raw)
+	flipped[len(flipped)-1] ^= 1
+	tests := []struct {
+		name  string
+		value []byte
+	}{
+		{"empty", nil},
+		{"unknown version", append([]byte{2}, raw[1:]...)},
+		{"truncated header", []byte(raw[:2])},
+		{"truncated wrapped key", []byte(raw[:5])},
+		{"truncated nonce", []byte(raw[:3+len(fakeWrapPrefix)+32+4])},
+		{"truncated ciphertext", []byte(raw[:len(raw)-1])},
+		{"flipped bit", flipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := inner.Set(ctx, "tampered", tt.value, time.Minute); err != nil {
+				t.Fatalf("inner Set: %v", err)
+			}
+			if got, err := c.Get(ctx, "tampered"); err == nil {
+				t.Fatalf("Get = %q, want an error", got)
+			}
+		})
+	}
+
+	t.Run("moved to another key", func(t *testing.T) {
+		if err := inner.Set(ctx, "patient:2", raw, time.Minute); err != nil {
+			t.Fatalf("inner Set: %v", err)
+		}
+		if got, err := c.Get(ctx, "patient:2"); err == nil {
+			t.Fatalf("Get = %q, want an error", got)
+		}
+	})
+}
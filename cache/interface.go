@@ -5,10 +5,33 @@ import (
 	"time"
 )
 
+// ErrMiss is returned by every backend when a key is absent or expired.
+// It reports NotFound so packages such as apperr can map it without
+// importing cache.
+var ErrMiss error = missError{}
+
+type missError struct{}
+
+func (missError) Error() string  { return "cache: key not found" }
+func (missError) NotFound() bool { return true }
+
 type Client interface {
+	// Get returns the stored value or ErrMiss.
 	Get(ctx context.Context, key string) (string, error)
+	// Set stores value encoded through the package codec.
 	Set(ctx context.Context, key string, value any, expiration time.Duration) error
 	Ping(ctx context.Context) error
 	Del(ctx context.Context, key string) error
 	Close() error
+
+	// MGet returns the values found, missing keys are left out of the map.
+	MGet(ctx context.Context, keys ...string) (map[string]string, error)
+	// MSet stores every value with the same expiration.
+	MSet(ctx context.Context, values map[string]any, expiration time.Duration) error
+	// DelByPrefix removes every key starting with prefix.
+	DelByPrefix(ctx context.Context, prefix string) error
+	// SetWithTags stores value and records key under each tag.
+	SetWithTags(ctx context.Context, key string, value any, expiration time.Duration, tags ...string) error
+	// InvalidateTags removes every key recorded under any of tags.
+	InvalidateTags(ctx context.Context, tags ...string) error
 }
@@ -2,42 +2,247 @@ package cache
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"sync"
 	"time"
 
-	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/hashicorp/golang-lru/v2/simplelru"
 )
 
+const defaultCleanupInterval = time.Minute
+
+// LocalOptions configures the bounds and housekeeping of a LocalCache.
+type LocalOptions struct {
+	// MaxEntries caps the number of entries held at once, it may be left out
+	// when MaxBytes is set.
+	MaxEntries int
+	// MaxBytes caps the total size of stored values, zero means unbounded.
+	MaxBytes int64
+	// CleanupInterval is how often expired entries are swept, defaults to a minute.
+	CleanupInterval time.Duration
+}
+
+type entry struct {
+	value     []byte
+	size      int64
+	expiresAt time.Time
+	tags      []string
+	// original is the value given to LegacyLRU.Add, which its readers return.
+	original any
+	legacy   bool
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// LocalCache is an in-process LRU cache with per-entry expiration.
 type LocalCache struct {
-	LRU *lru.Cache[string, any]
+	// Deprecated: LRU only remains for existing callers, use Get, Set and Del.
+	LRU *LegacyLRU
+
+	mu          sync.Mutex
+	lru         *simplelru.LRU[string, *entry]
+	bytes       int64
+	maxBytes    int64
+	locks       map[string]*localLock
+	fences      map[string]*localFence
+	rates       map[string]*localRate
+	tags        map[string]map[string]struct{}
+	interval    time.Duration
+	janitorOnce sync.Once
+	done        chan struct{}
+	once        sync.Once
 }
 
+// NewLocalCache returns a cache holding up to size entries. A cleanup
+// goroutine starts once entries with an expiration, locks or rate limits are
+// used, call Close to stop it.
 func NewLocalCache(size int) (*LocalCache, error) {
-	core, err := lru.New[string, any](size)
+	return NewLocalCacheWithOptions(&LocalOptions{MaxEntries: size})
+}
+
+// NewLocalCacheWithOptions is NewLocalCache with explicit bounds, the same
+// Close requirement applies.
+func NewLocalCacheWithOptions(opts *LocalOptions) (*LocalCache, error) {
+	if opts == nil {
+		return nil, fmt.Errorf("local cache options are required")
+	}
+	c := &LocalCache{
+		maxBytes: opts.MaxBytes,
+		locks:    make(map[string]*localLock),
+		fences:   make(map[string]*localFence),
+		rates:    make(map[string]*localRate),
+		tags:     make(map[string]map[string]struct{}),
+		done:     make(chan struct{}),
+	}
+	maxEntries := opts.MaxEntries
+	if maxEntries <= 0 && opts.MaxBytes > 0 {
+		// The byte budget bounds the cache on its own.
+		maxEntries = math.MaxInt
+	}
+	core, err := simplelru.NewLRU(maxEntries, c.onEvict)
 	if err != nil {
 		return nil, err
 	}
-	return &LocalCache{LRU: core}, nil
+	c.lru = core
+	c.LRU = &LegacyLRU{cache: c}
+
+	c.interval = opts.CleanupInterval
+	if c.interval <= 0 {
+		c.interval = defaultCleanupInterval
+	}
+	return c, nil
+}
+
+// onEvict keeps the byte accounting and tag index in sync, it runs with mu held.
+func (m *LocalCache) onEvict(key string, e *entry) {
+	m.bytes -= e.size
+	m.untag(key, e)
+}
+
+func (m *LocalCache) untag(key string, e *entry) {
+	for _, tag := range e.tags {
+		delete(m.tags[tag], key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+}
+
+// startJanitor runs the cleanup goroutine once there is state that expires.
+func (m *LocalCache) startJanitor() {
+	m.janitorOnce.Do(func() { go m.janitor() })
+}
+
+func (m *LocalCache) janitor() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.deleteExpired()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *LocalCache) deleteExpired() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, key := range m.lru.Keys() {
+		if e, ok := m.lru.Peek(key); ok && e.expired(now) {
+			m.lru.Remove(key)
+		}
+	}
+	for key, lock := range m.locks {
+		if now.After(lock.expiresAt) {
+			delete(m.locks, key)
+		}
+	}
+	for key, fence := range m.fences {
+		if _, locked := m.locks[key]; !locked && now.After(fence.expiresAt) {
+			delete(m.fences, key)
+		}
+	}
+	for key, rate := range m.rates {
+		if now.After(rate.expiresAt) {
+			delete(m.rates, key)
+		}
+	}
 }
 
 func (m *LocalCache) Get(ctx context.Context, key string) (string, error) {
-	value, ok := m.LRU.Get(key)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.get(key, time.Now())
+}
+
+// get looks up a live entry, it runs with mu held.
+func (m *LocalCache) get(key string, now time.Time) (string, error) {
+	e, ok := m.lru.Get(key)
 	if !ok {
-		return "", nil
+		return "", ErrMiss
 	}
-	return value.(string), nil
+	if e.expired(now) {
+		m.lru.Remove(key)
+		return "", ErrMiss
+	}
+	if e.legacy && e.value == nil {
+		// Added through LegacyLRU with a value that has no encoding.
+		return "", ErrMiss
+	}
+	return string(e.value), nil
 }
 
 func (m *LocalCache) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
-	m.LRU.Add(key, value)
-	return nil
+	return m.SetWithTags(ctx, key, value, expiration)
+}
+
+func (m *LocalCache) newEntry(key string, value any, expiration time.Duration, tags []string) (*entry, error) {
+	data, err := encode(value)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{value: data, size: int64(len(data)), tags: tags}
+	if expiration > 0 {
+		e.expiresAt = time.Now().Add(expiration)
+	}
+	if m.maxBytes > 0 && e.size > m.maxBytes {
+		return nil, fmt.Errorf("value for key %q exceeds the cache size limit", key)
+	}
+	return e, nil
+}
+
+// add stores an entry and enforces the byte budget, it runs with mu held.
+func (m *LocalCache) add(key string, e *entry) {
+	// Replacing a key does not trigger the eviction callback.
+	if old, ok := m.lru.Peek(key); ok {
+		m.bytes -= old.size
+		m.untag(key, old)
+	}
+	m.lru.Add(key, e)
+	m.bytes += e.size
+	if !e.expiresAt.IsZero() {
+		m.startJanitor()
+	}
+	for _, tag := range e.tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[string]struct{})
+		}
+		m.tags[tag][key] = struct{}{}
+	}
+	for m.maxBytes > 0 && m.bytes > m.maxBytes {
+		if _, _, ok := m.lru.RemoveOldest(); !ok {
+			break
+		}
+	}
 }
 
 func (m *LocalCache) Del(ctx context.Context, key string) error {
-	m.LRU.Remove(key)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lru.Remove(key)
 	return nil
 }
 
+// Purge drops every entry.
+func (m *LocalCache) Purge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lru.Purge()
+}
+
+// Close stops the background janitor, if it was started.
 func (m *LocalCache) Close() error {
+	m.once.Do(func() {
+		// Keep later expiring writes from starting it again.
+		m.janitorOnce.Do(func() {})
+		close(m.done)
+	})
 	return nil
 }
 
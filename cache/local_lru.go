@@ -0,0 +1,98 @@
+package cache
+
+import "time"
+
+// LegacyLRU keeps the method set of the LRU field LocalCache used to expose,
+// backed by the cache itself so both views see the same entries.
+//
+// Deprecated: use the LocalCache methods instead.
+type LegacyLRU struct {
+	cache *LocalCache
+}
+
+// Add stores value without expiration and reports whether an entry was
+// evicted. The LegacyLRU readers return value itself, LocalCache.Get returns
+// its encoding and misses when value has none.
+func (l *LegacyLRU) Add(key string, value any) (evicted bool) {
+	m := l.cache
+	e := &entry{original: value, legacy: true}
+	if data, err := encode(value); err == nil {
+		e.value, e.size = data, int64(len(data))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	want := m.lru.Len()
+	if !m.lru.Contains(key) {
+		want++
+	}
+	m.add(key, e)
+	return m.lru.Len() < want
+}
+
+// Get returns the value given to Add, or the stored string for entries set
+// through LocalCache.
+func (l *LegacyLRU) Get(key string) (value any, ok bool) {
+	m := l.cache
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.lru.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if e.expired(time.Now()) {
+		m.lru.Remove(key)
+		return nil, false
+	}
+	return e.legacyValue(), true
+}
+
+// Peek is Get without updating the recency of the key.
+func (l *LegacyLRU) Peek(key string) (value any, ok bool) {
+	m := l.cache
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.lru.Peek(key)
+	if !ok || e.expired(time.Now()) {
+		return nil, false
+	}
+	return e.legacyValue(), true
+}
+
+func (e *entry) legacyValue() any {
+	if e.legacy {
+		return e.original
+	}
+	return string(e.value)
+}
+
+func (l *LegacyLRU) Contains(key string) bool {
+	_, ok := l.Peek(key)
+	return ok
+}
+
+func (l *LegacyLRU) Remove(key string) (present bool) {
+	m := l.cache
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Remove(key)
+}
+
+// Keys returns the keys from oldest to newest, including expired entries
+// the janitor has not swept yet.
+func (l *LegacyLRU) Keys() []string {
+	m := l.cache
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Keys()
+}
+
+func (l *LegacyLRU) Len() int {
+	m := l.cache
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+func (l *LegacyLRU) Purge() {
+	l.cache.Purge()
+}
@@ -0,0 +1,18 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/clineomx/trussrod/cache"
+	"github.com/clineomx/trussrod/cache/cachetest"
+)
+
+func TestLocalCacheConformance(t *testing.T) {
+	cachetest.Run(t, func(t *testing.T) cache.Client {
+		c, err := cache.NewLocalCacheWithOptions(&cache.LocalOptions{MaxEntries: 1024})
+		if err != nil {
+			t.Fatalf("NewLocalCacheWithOptions: %v", err)
+		}
+		return c
+	})
+}
@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrNotAcquired is returned when the lock is held by another owner.
+	ErrNotAcquired = errors.New("cache: lock is held by another owner")
+	// ErrLockLost is returned when a lease expired or was taken over.
+	ErrLockLost = errors.New("cache: lock is no longer held")
+)
+
+// Locker hands out exclusive, expiring leases over a key.
+type Locker interface {
+	// Acquire makes a single attempt to take the lock, returning
+	// ErrNotAcquired when someone else holds it.
+	Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error)
+}
+
+// Lease is a held lock.
+type Lease interface {
+	// Token is a fencing token that grows with every acquisition of the
+	// same key, downstream writes can reject tokens older than the last seen.
+	Token() int64
+	// Refresh extends the lease to ttl from now.
+	Refresh(ctx context.Context, ttl time.Duration) error
+	// Release frees the lock if it is still held by this lease.
+	Release(ctx context.Context) error
+}
+
+func lockKey(key string) string {
+	// The hash tag keeps the lock and its fence in the same cluster slot.
+	return "lock:{" + key + "}"
+}
+
+func fenceKey(key string) string {
+	return lockKey(key) + ":fence"
+}
+
+// fenceRetention is how long a fence counter outlives the last lease on its
+// key. Tokens keep growing as long as the key is locked again within it,
+// while counters of keys that are never locked again don't pile up.
+const fenceRetention = 24 * time.Hour
+
+func fenceTTL(ttl time.Duration) time.Duration {
+	return max(fenceRetention, 10*ttl)
+}
+
+var acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	local token = redis.call("INCR", KEYS[2])
+	redis.call("PEXPIRE", KEYS[2], ARGV[3])
+	return token
+end
+return 0
+`)
+
+var refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[2], ARGV[3])
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type redisLease struct {
+	conn  redis.UniversalClient
+	key   string
+	fence string
+	owner string
+	token int64
+}
+
+func (c *RedisClient) Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error) {
+	owner := uuid.NewString()
+	token, err := acquireScript.Run(ctx, c.conn,
+		[]string{lockKey(key), fenceKey(key)},
+		owner, ttl.Milliseconds(), fenceTTL(ttl).Milliseconds(),
+	).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if token == 0 {
+		return nil, ErrNotAcquired
+	}
+	return &redisLease{conn: c.conn, key: lockKey(key), fence: fenceKey(key), owner: owner, token: token}, nil
+}
+
+func (l *redisLease) Token() int64 {
+	return l.token
+}
+
+func (l *redisLease) Refresh(ctx context.Context, ttl time.Duration) error {
+	ok, err := refreshScript.Run(ctx, l.conn,
+		[]string{l.key, l.fence},
+		l.owner, ttl.Milliseconds(), fenceTTL(ttl).Milliseconds(),
+	).Int64()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (l *redisLease) Release(ctx context.Context) error {
+	ok, err := releaseScript.Run(ctx, l.conn, []string{l.key}, l.owner).Int64()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+type localLock struct {
+	owner     string
+	expiresAt time.Time
+}
+
+type localFence struct {
+	token     int64
+	expiresAt time.Time
+}
+
+type localLease struct {
+	cache *LocalCache
+	key   string
+	owner string
+	token int64
+}
+
+// Acquire takes an in-process lock, meant for tests and single-replica setups.
+// Locks live apart from cached entries so LRU eviction never drops them.
+func (m *LocalCache) Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if held, ok := m.locks[key]; ok && time.Now().Before(held.expiresAt) {
+		return nil, ErrNotAcquired
+	}
+	owner := uuid.NewString()
+	now := time.Now()
+	m.locks[key] = &localLock{owner: owner, expiresAt: now.Add(ttl)}
+	m.startJanitor()
+	fence, ok := m.fences[key]
+	if !ok {
+		fence = &localFence{}
+		m.fences[key] = fence
+	}
+	fence.token++
+	fence.expiresAt = now.Add(fenceTTL(ttl))
+	return &localLease{cache: m, key: key, owner: owner, token: fence.token}, nil
+}
+
+// held reports whether the lease still owns its lock, it runs with mu held.
+func (l *localLease) held() bool {
+	lock, ok := l.cache.locks[l.key]
+	return ok && lock.owner == l.owner && time.Now().Before(lock.expiresAt)
+}
+
+func (l *localLease) Token() int64 {
+	return l.token
+}
+
+func (l *localLease) Refresh(ctx context.Context, ttl time.Duration) error {
+	l.cache.mu.Lock()
+	defer l.cache.mu.Unlock()
+	if !l.held() {
+		return ErrLockLost
+	}
+	now := time.Now()
+	l.cache.locks[l.key].expiresAt = now.Add(ttl)
+	if fence, ok := l.cache.fences[l.key]; ok {
+		fence.expiresAt = now.Add(fenceTTL(ttl))
+	}
+	return nil
+}
+
+func (l *localLease) Release(ctx context.Context) error {
+	l.cache.mu.Lock()
+	defer l.cache.mu.Unlock()
+	if !l.held() {
+		return ErrLockLost
+	}
+	delete(l.cache.locks, l.key)
+	return nil
+}
+
+func (c *TieredCache) Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error) {
+	return c.remote.Acquire(ctx, key, ttl)
+}
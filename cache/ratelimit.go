@@ -0,0 +1,231 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type RateAlgorithm int
+
+const (
+	// TokenBucket refills Limit tokens evenly over Period and allows bursts up to Limit.
+	TokenBucket RateAlgorithm = iota
+	// SlidingWindow allows at most Limit hits within any trailing Period.
+	SlidingWindow
+)
+
+// Rate describes how many hits a key may spend over a period.
+type Rate struct {
+	Algorithm RateAlgorithm
+	Limit     int
+	Period    time.Duration
+}
+
+// RateResult is the outcome of a single hit against a Rate.
+type RateResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// RateLimiter counts hits per key.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, rate Rate) (*RateResult, error)
+}
+
+func rateKey(key string) string {
+	return "ratelimit:{" + key + "}"
+}
+
+func validateRate(rate Rate) error {
+	if rate.Limit <= 0 || rate.Period <= 0 {
+		return fmt.Errorf("invalid rate: limit and period must be positive")
+	}
+	return nil
+}
+
+// tokenBucketScript returns {allowed, tokens left, ms until next token}.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+redis.replicate_commands()
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local rate = capacity / period
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], period)
+
+local wait = 0
+if tokens < 1 then
+	wait = math.ceil((1 - tokens) / rate)
+end
+return {allowed, tostring(tokens), wait}
+`)
+
+// slidingWindowScript returns {allowed, hits in window, ms until the oldest hit leaves}.
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+redis.replicate_commands()
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - period)
+local count = redis.call("ZCARD", KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call("ZADD", KEYS[1], now, ARGV[3])
+	count = count + 1
+	allowed = 1
+end
+redis.call("PEXPIRE", KEYS[1], period)
+
+local wait = 0
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if oldest[2] then
+	wait = tonumber(oldest[2]) + period - now
+end
+return {allowed, count, wait}
+`)
+
+// Allow runs the limiter in a script reading the Redis clock, so app servers
+// with skewed clocks still agree on windows and refills.
+func (c *RedisClient) Allow(ctx context.Context, key string, rate Rate) (*RateResult, error) {
+	if err := validateRate(rate); err != nil {
+		return nil, err
+	}
+	period := rate.Period.Milliseconds()
+
+	switch rate.Algorithm {
+	case TokenBucket:
+		res, err := tokenBucketScript.Run(ctx, c.conn, []string{rateKey(key)}, rate.Limit, period).Slice()
+		if err != nil {
+			return nil, err
+		}
+		tokens, err := strconv.ParseFloat(fmt.Sprint(res[1]), 64)
+		if err != nil {
+			return nil, err
+		}
+		return bucketResult(rate, res[0].(int64) == 1, tokens, time.Duration(res[2].(int64))*time.Millisecond), nil
+	case SlidingWindow:
+		res, err := slidingWindowScript.Run(ctx, c.conn, []string{rateKey(key)}, rate.Limit, period, uuid.NewString()).Slice()
+		if err != nil {
+			return nil, err
+		}
+		return windowResult(rate, res[0].(int64) == 1, int(res[1].(int64)), time.Duration(res[2].(int64))*time.Millisecond), nil
+	default:
+		return nil, fmt.Errorf("unknown rate algorithm %d", rate.Algorithm)
+	}
+}
+
+func bucketResult(rate Rate, allowed bool, tokens float64, wait time.Duration) *RateResult {
+	refill := float64(rate.Period) / float64(rate.Limit)
+	result := &RateResult{
+		Allowed:    allowed,
+		Limit:      rate.Limit,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration((float64(rate.Limit) - tokens) * refill),
+	}
+	if !allowed {
+		result.RetryAfter = wait
+	}
+	return result
+}
+
+func windowResult(rate Rate, allowed bool, count int, wait time.Duration) *RateResult {
+	result := &RateResult{
+		Allowed:    allowed,
+		Limit:      rate.Limit,
+		Remaining:  max(rate.Limit-count, 0),
+		ResetAfter: wait,
+	}
+	if !allowed {
+		result.RetryAfter = wait
+	}
+	return result
+}
+
+type localRate struct {
+	tokens    float64
+	hits      []time.Time
+	updatedAt time.Time
+	expiresAt time.Time
+}
+
+// Allow counts hits in process memory, state for idle keys is swept by the janitor.
+func (m *LocalCache) Allow(ctx context.Context, key string, rate Rate) (*RateResult, error) {
+	if err := validateRate(rate); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.rates[key]
+	if !ok || now.After(state.expiresAt) {
+		state = &localRate{tokens: float64(rate.Limit), updatedAt: now}
+		m.rates[key] = state
+		m.startJanitor()
+	}
+	state.expiresAt = now.Add(rate.Period)
+
+	switch rate.Algorithm {
+	case TokenBucket:
+		perToken := float64(rate.Period) / float64(rate.Limit)
+		elapsed := float64(now.Sub(state.updatedAt))
+		state.tokens = math.Min(float64(rate.Limit), state.tokens+elapsed/perToken)
+		state.updatedAt = now
+		allowed := state.tokens >= 1
+		if allowed {
+			state.tokens--
+		}
+		var wait time.Duration
+		if state.tokens < 1 {
+			wait = time.Duration(math.Ceil((1 - state.tokens) * perToken))
+		}
+		return bucketResult(rate, allowed, state.tokens, wait), nil
+	case SlidingWindow:
+		cutoff := now.Add(-rate.Period)
+		i := 0
+		for i < len(state.hits) && !state.hits[i].After(cutoff) {
+			i++
+		}
+		state.hits = state.hits[i:]
+		allowed := len(state.hits) < rate.Limit
+		if allowed {
+			state.hits = append(state.hits, now)
+		}
+		var wait time.Duration
+		if len(state.hits) > 0 {
+			wait = state.hits[0].Add(rate.Period).Sub(now)
+		}
+		return windowResult(rate, allowed, len(state.hits), wait), nil
+	default:
+		return nil, fmt.Errorf("unknown rate algorithm %d", rate.Algorithm)
+	}
+}
+
+func (c *TieredCache) Allow(ctx context.Context, key string, rate Rate) (*RateResult, error) {
+	return c.remote.Allow(ctx, key, rate)
+}
@@ -2,15 +2,19 @@ package cache
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/clineomx/trussrod/settings"
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisClient struct {
-	conn *redis.Client
+	conn redis.UniversalClient
 }
 
 func NewRedisClient(host, port, password, db string) (*RedisClient, error) {
@@ -19,25 +23,122 @@ func NewRedisClient(host, port, password, db string) (*RedisClient, error) {
 	if err != nil {
 		return nil, err
 	}
-	client := &RedisClient{
-		conn: redis.NewClient(&redis.Options{
-			Addr:     uri,
-			Password: password,
-			DB:       dbInt,
-		}),
+	return newRedisClient(redis.NewClient(&redis.Options{
+		Addr:     uri,
+		Password: password,
+		DB:       dbInt,
+	}))
+}
+
+// NewRedisClientFromConfig builds a standalone, sentinel or cluster client
+// from the cache settings, with ACL user, TLS, pool and timeout options.
+func NewRedisClientFromConfig(cfg *settings.CacheConfig) (*RedisClient, error) {
+	opts, err := universalOptions(cfg)
+	if err != nil {
+		return nil, err
 	}
+	return newRedisClient(redis.NewUniversalClient(opts))
+}
+
+func newRedisClient(conn redis.UniversalClient) (*RedisClient, error) {
+	client := &RedisClient{conn: conn}
 	if err := client.conn.Ping(context.Background()).Err(); err != nil {
+		conn.Close()
 		return nil, err
 	}
 	return client, nil
 }
 
+func universalOptions(cfg *settings.CacheConfig) (*redis.UniversalOptions, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("cache config is required")
+	}
+	opts := &redis.UniversalOptions{
+		Username:         cfg.User,
+		Password:         cfg.Password,
+		DB:               cfg.DB,
+		SentinelUsername: cfg.SentinelUser,
+		SentinelPassword: cfg.SentinelPassword,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+	}
+
+	var addrs []string
+	for _, addr := range strings.Split(cfg.Addrs, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 && cfg.Host != "" {
+		addrs = []string{fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("cache config has no address")
+	}
+	opts.Addrs = addrs
+
+	switch strings.ToLower(cfg.Mode) {
+	case "", "standalone":
+		if len(addrs) > 1 {
+			return nil, fmt.Errorf("standalone cache mode takes a single address")
+		}
+	case "sentinel":
+		if cfg.MasterName == "" {
+			return nil, fmt.Errorf("sentinel cache mode requires a master name")
+		}
+		opts.MasterName = cfg.MasterName
+	case "cluster":
+		if cfg.DB != 0 {
+			return nil, fmt.Errorf("cluster cache mode only supports database 0")
+		}
+		opts.IsClusterMode = true
+	default:
+		return nil, fmt.Errorf("unknown cache mode %q", cfg.Mode)
+	}
+
+	if cfg.TLS {
+		opts.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: cfg.TLSServerName,
+		}
+	}
+
+	timeouts := []struct {
+		value  string
+		target *time.Duration
+	}{
+		{cfg.DialTimeout, &opts.DialTimeout},
+		{cfg.ReadTimeout, &opts.ReadTimeout},
+		{cfg.WriteTimeout, &opts.WriteTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(t.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache timeout %q: %w", t.value, err)
+		}
+		*t.target = d
+	}
+
+	return opts, nil
+}
+
 func (c *RedisClient) Get(ctx context.Context, key string) (string, error) {
-	return c.conn.Get(ctx, key).Result()
+	value, err := c.conn.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrMiss
+	}
+	return value, err
 }
 
 func (c *RedisClient) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
-	return c.conn.Set(ctx, key, value, expiration).Err()
+	data, err := encode(value)
+	if err != nil {
+		return err
+	}
+	return c.conn.Set(ctx, key, data, expiration).Err()
 }
 
 func (c *RedisClient) Close() error {
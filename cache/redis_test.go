@@ -0,0 +1,35 @@
+package cache_test
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/clineomx/trussrod/cache"
+	"github.com/clineomx/trussrod/cache/cachetest"
+)
+
+// TestRedisClientConformance runs against the server in REDIS_ADDR, such as
+// localhost:6379, using the database in REDIS_DB or 0.
+func TestRedisClientConformance(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("invalid REDIS_ADDR %q: %v", addr, err)
+	}
+	db := os.Getenv("REDIS_DB")
+	if db == "" {
+		db = "0"
+	}
+
+	cachetest.Run(t, func(t *testing.T) cache.Client {
+		c, err := cache.NewRedisClient(host, port, os.Getenv("REDIS_PASSWORD"), db)
+		if err != nil {
+			t.Fatalf("NewRedisClient: %v", err)
+		}
+		return c
+	})
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultInvalidationChannel = "trussrod:cache:invalidate"
+	defaultLocalTTL            = 30 * time.Second
+)
+
+type invalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys,omitempty"`
+	Prefix string   `json:"prefix,omitempty"`
+}
+
+// TieredCache keeps a per-process LocalCache (L1) in front of a RedisClient (L2).
+// Writes and deletes are broadcast over Redis pub/sub so that other replicas
+// drop their copy from L1. Entries never live in L1 longer than localTTL,
+// which bounds staleness should an invalidation message be lost.
+type TieredCache struct {
+	local    *LocalCache
+	remote   *RedisClient
+	localTTL time.Duration
+	channel  string
+	origin   string
+	sub      *redis.PubSub
+	done     chan struct{}
+}
+
+// NewTieredCache wires local in front of remote and starts listening for
+// invalidations on channel, an empty channel uses the package default.
+// A localTTL of zero or less defaults to 30s, L1 entries always expire so
+// an invalidation racing a read can't leave a stale value behind for good.
+func NewTieredCache(local *LocalCache, remote *RedisClient, localTTL time.Duration, channel string) (*TieredCache, error) {
+	if channel == "" {
+		channel = defaultInvalidationChannel
+	}
+	if localTTL <= 0 {
+		localTTL = defaultLocalTTL
+	}
+	c := &TieredCache{
+		local:    local,
+		remote:   remote,
+		localTTL: localTTL,
+		channel:  channel,
+		origin:   uuid.NewString(),
+		done:     make(chan struct{}),
+	}
+
+	c.sub = remote.conn.Subscribe(context.Background(), channel)
+	// Wait for the subscription to be confirmed before serving reads.
+	if _, err := c.sub.Receive(context.Background()); err != nil {
+		c.sub.Close()
+		return nil, err
+	}
+	go c.listen()
+
+	return c, nil
+}
+
+func (c *TieredCache) listen() {
+	defer close(c.done)
+	for msg := range c.sub.ChannelWithSubscriptions() {
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			// The subscription was re-established after a reconnect and
+			// invalidations may have been missed in between.
+			if m.Kind == "subscribe" {
+				c.local.Purge()
+			}
+		case *redis.Message:
+			var inv invalidation
+			if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil {
+				continue
+			}
+			if inv.Origin == c.origin {
+				continue
+			}
+			for _, key := range inv.Keys {
+				c.local.Del(context.Background(), key)
+			}
+			if inv.Prefix != "" {
+				c.local.DelByPrefix(context.Background(), inv.Prefix)
+			}
+		}
+	}
+}
+
+func (c *TieredCache) publish(ctx context.Context, inv invalidation) error {
+	inv.Origin = c.origin
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return c.remote.conn.Publish(ctx, c.channel, payload).Err()
+}
+
+// boundTTL returns the lifetime of an L1 entry given the L2 lifetime.
+func (c *TieredCache) boundTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > c.localTTL {
+		return c.localTTL
+	}
+	return ttl
+}
+
+func (c *TieredCache) Get(ctx context.Context, key string) (string, error) {
+	if value, err := c.local.Get(ctx, key); err == nil {
+		return value, nil
+	}
+
+	values, ttls, err := c.remote.getWithTTL(ctx, true, key)
+	if err != nil {
+		return "", err
+	}
+	value, ok := values[key]
+	if !ok {
+		return "", ErrMiss
+	}
+	c.local.Set(ctx, key, value, c.boundTTL(ttls[key]))
+	return value, nil
+}
+
+func (c *TieredCache) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	if err := c.remote.Set(ctx, key, value, expiration); err != nil {
+		return err
+	}
+	if err := c.local.Set(ctx, key, value, c.boundTTL(expiration)); err != nil {
+		return err
+	}
+	return c.publish(ctx, invalidation{Keys: []string{key}})
+}
+
+func (c *TieredCache) Del(ctx context.Context, key string) error {
+	c.local.Del(ctx, key)
+	if err := c.remote.Del(ctx, key); err != nil {
+		return err
+	}
+	return c.publish(ctx, invalidation{Keys: []string{key}})
+}
+
+func (c *TieredCache) Ping(ctx context.Context) error {
+	return c.remote.Ping(ctx)
+}
+
+// Close stops listening for invalidations and closes both tiers.
+func (c *TieredCache) Close() error {
+	err := c.sub.Close()
+	<-c.done
+	return errors.Join(err, c.local.Close(), c.remote.Close())
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Codec marshals typed values into the bytes stored in a Client.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the default Codec used by Typed.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// Loader fetches the value for a key when it is not cached.
+type Loader[T any] func(ctx context.Context) (T, error)
+
+// Typed wraps a Client to store and retrieve values of type T.
+type Typed[T any] struct {
+	client Client
+	codec  Codec
+	group  singleflight.Group
+}
+
+// NewTyped creates a typed view over client, codec defaults to JSONCodec when nil.
+func NewTyped[T any](client Client, codec Codec) *Typed[T] {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &Typed[T]{client: client, codec: codec}
+}
+
+// Get returns the decoded value for key or ErrMiss.
+func (t *Typed[T]) Get(ctx context.Context, key string) (T, error) {
+	var value T
+	raw, err := t.client.Get(ctx, key)
+	if err != nil {
+		return value, err
+	}
+	if err := t.codec.Unmarshal([]byte(raw), &value); err != nil {
+		return value, err
+	}
+	return value, nil
+}
+
+func (t *Typed[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	data, err := t.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return t.client.Set(ctx, key, data, ttl)
+}
+
+// GetOrLoad returns the cached value for key, or calls loader and caches
+// its result for ttl. Concurrent callers missing the same key share a
+// single loader call, which runs detached from their cancellation so
+// loaders should apply their own timeouts. The cache is treated as best effort, so read and
+// write failures fall back to the loader instead of failing the call.
+func (t *Typed[T]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader Loader[T]) (T, error) {
+	if value, err := t.Get(ctx, key); err == nil {
+		return value, nil
+	}
+
+	// The shared load must not fail for everyone when the caller that
+	// started it goes away, each caller's context only bounds its own wait.
+	shared := context.WithoutCancel(ctx)
+	ch := t.group.DoChan(key, func() (any, error) {
+		// Another caller may have filled the key while we were waiting.
+		if value, err := t.Get(shared, key); err == nil {
+			return value, nil
+		}
+
+		value, err := loader(shared)
+		if err != nil {
+			return value, err
+		}
+		_ = t.Set(shared, key, value, ttl)
+		return value, nil
+	})
+
+	var zero T
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		value, _ := res.Val.(T)
+		return value, nil
+	}
+}
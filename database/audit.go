@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/clineomx/trussrod/request"
+)
+
+// AuditTable is where audited changesets record their changes.
+const AuditTable = "audit_log"
+
+// AuditLogSchema creates the table used by audited changesets, include it
+// in a migration.
+const AuditLogSchema = `CREATE TABLE IF NOT EXISTS audit_log (
+	id bigserial PRIMARY KEY,
+	table_name text NOT NULL,
+	row_id text NOT NULL,
+	actor_id text,
+	actor_role text,
+	trace_id text,
+	old_values jsonb NOT NULL,
+	new_values jsonb NOT NULL,
+	changed_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS audit_log_row_idx ON audit_log (table_name, row_id, changed_at);`
+
+// Audit makes Exec record the old and new values of every set column in
+// audit_log, keyed by the primaryKey column. Build refuses audited
+// changesets so the audit rows can't be skipped by running it directly.
+func (c *Changeset) Audit(primaryKey string) *Changeset {
+	c.audit = primaryKey
+	return c
+}
+
+// Exec runs the UPDATE built from where and the conditions added with Where.
+// Audited changesets write their audit_log rows in the same statement, so
+// they commit or roll back with the update, and RETURNING is not applied.
+// With ExpectVersion a stale version results in a conflict, see ExpectVersion.
+func (c *Changeset) Exec(ctx context.Context, db DB, where string, whereArgs ...any) (Result, error) {
+	var (
+		query string
+		args  []any
+		err   error
+	)
+	if c.audit != "" {
+		query, args, err = c.buildAudited(ctx, where, whereArgs...)
+	} else {
+		query, args, err = c.Build(where, whereArgs...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result, err := db.Exec(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if c.version != nil && result.RowsAffected() == 0 {
+		return nil, c.versionConflict(ctx, db, where, whereArgs...)
+	}
+	return result, nil
+}
+
+// buildAudited joins the update with a locking subquery over the same rows,
+// so the old and new values come from a single row lock and every updated
+// row returns its audit values. The automatic updated_at and version columns
+// are recorded along with the set ones.
+func (c *Changeset) buildAudited(ctx context.Context, where string, whereArgs ...any) (string, []any, error) {
+	if c.err != nil {
+		return "", nil, c.err
+	}
+	if len(c.columns) == 0 {
+		return "", nil, fmt.Errorf("no fields to update")
+	}
+
+	clause, condArgs, index, err := whereClause(c.condition(true, where, whereArgs...), c.Index)
+	if err != nil {
+		return "", nil, err
+	}
+
+	audited := slices.Clone(c.columns)
+	if column, ok := stampedColumn(c.table, c.columns); ok {
+		audited = append(audited, column)
+	}
+	if c.version != nil {
+		audited = append(audited, VersionColumn)
+	}
+	values := func(qualifier string) string {
+		pairs := make([]string, len(audited))
+		for i, column := range audited {
+			pairs[i] = fmt.Sprintf("'%s', %s%s", column, qualifier, column)
+		}
+		return "jsonb_build_object(" + strings.Join(pairs, ", ") + ")"
+	}
+
+	var actorID, actorRole, traceID *string
+	if user, ok := ctx.Value(request.ClineoUser).(*request.User); ok && user != nil {
+		actorID, actorRole = &user.ID, &user.Role
+	}
+	if id, ok := ctx.Value(request.ClineoTraceID).(string); ok {
+		traceID = &id
+	}
+
+	// The subquery columns are prefixed so they can't shadow the table's
+	// columns in the SET expressions.
+	query := fmt.Sprintf(`WITH changed AS (UPDATE %[1]s SET %[2]s `+
+		`FROM (SELECT %[3]s AS audit_pk, %[4]s AS audit_old FROM %[1]s%[5]s FOR UPDATE) audit_prior `+
+		`WHERE %[1]s.%[3]s = audit_prior.audit_pk `+
+		`RETURNING %[1]s.%[3]s::text AS row_id, audit_prior.audit_old AS old_values, %[6]s AS new_values) `+
+		`INSERT INTO %[7]s (table_name, row_id, actor_id, actor_role, trace_id, old_values, new_values) `+
+		`SELECT $%[8]d, row_id, $%[9]d, $%[10]d, $%[11]d, old_values, new_values FROM changed`,
+		c.table,
+		c.setClauses(),
+		c.audit,
+		values(""),
+		clause,
+		values(c.table+"."),
+		AuditTable,
+		index, index+1, index+2, index+3,
+	)
+	args := append(slices.Clip(c.args), condArgs...)
+	args = append(args, c.table, actorID, actorRole, traceID)
+	return query, args, nil
+}
@@ -2,14 +2,33 @@ package database
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+	"sync"
 )
 
+// updatedAtColumns maps tables to the column stamped on every update.
+var updatedAtColumns sync.Map
+
+// AutoUpdatedAt makes every Changeset update on table set column to now(),
+// unless the column is set explicitly.
+func AutoUpdatedAt(table, column string) {
+	updatedAtColumns.Store(table, column)
+}
+
 type Changeset struct {
-	table   string
-	clauses []string
-	args    []any
-	Index   int
+	table     string
+	columns   []string
+	values    []string
+	args      []any
+	returning []string
+	conflict  *onConflict
+	where     Cond
+	rowID     string
+	audit     string
+	version   *int64
+	err       error
+	Index     int
 }
 
 func NewChangeset(table string) *Changeset {
@@ -20,12 +39,44 @@ func NewChangeset(table string) *Changeset {
 }
 
 func (c *Changeset) Set(column string, value any) *Changeset {
-	c.clauses = append(c.clauses, fmt.Sprintf("%s = $%d", column, c.Index))
+	c.columns = append(c.columns, column)
+	c.values = append(c.values, fmt.Sprintf("$%d", c.Index))
 	c.args = append(c.args, value)
 	c.Index++
 	return c
 }
 
+// SetIfNotNil sets column to the pointed value and skips nil pointers.
+func SetIfNotNil[T any](c *Changeset, column string, value *T) *Changeset {
+	if value != nil {
+		c.Set(column, *value)
+	}
+	return c
+}
+
+// SetNull sets column to an explicit NULL.
+func (c *Changeset) SetNull(column string) *Changeset {
+	c.columns = append(c.columns, column)
+	c.values = append(c.values, "NULL")
+	return c
+}
+
+// SetExpr sets column to a SQL expression such as "now()" or "counter + ?".
+// Each ? is bound to the matching arg as a numbered placeholder, use ?? for
+// a literal question mark.
+func (c *Changeset) SetExpr(column, expr string, args ...any) *Changeset {
+	bound, index, err := bindPlaceholders(expr, c.Index, len(args))
+	if err != nil {
+		c.err = err
+		return c
+	}
+	c.columns = append(c.columns, column)
+	c.values = append(c.values, bound)
+	c.args = append(c.args, args...)
+	c.Index = index
+	return c
+}
+
 func (c *Changeset) SetStringIfNotNil(column string, value *string) *Changeset {
 	if value != nil {
 		c.Set(column, *value)
@@ -40,26 +91,102 @@ func (c *Changeset) SetBytesIfNotNil(column string, value *[]byte) *Changeset {
 	return c
 }
 
+// Where adds a condition to the UPDATE, conditions are combined with AND.
+func (c *Changeset) Where(cond Cond) *Changeset {
+	c.where = And(c.where, cond)
+	return c
+}
+
+func (c *Changeset) And(cond Cond) *Changeset {
+	return c.Where(cond)
+}
+
+// Or combines every condition added so far with cond using OR.
+func (c *Changeset) Or(cond Cond) *Changeset {
+	c.where = Or(c.where, cond)
+	return c
+}
+
+// Returning adds a RETURNING clause to the built statement.
+func (c *Changeset) Returning(columns ...string) *Changeset {
+	c.returning = append(c.returning, columns...)
+	return c
+}
+
+func (c *Changeset) clauses() []string {
+	clauses := make([]string, len(c.columns))
+	for i, column := range c.columns {
+		clauses[i] = fmt.Sprintf("%s = %s", column, c.values[i])
+	}
+	return clauses
+}
+
+// stamp returns the automatic updated_at assignment for the table, if any.
+func (c *Changeset) stamp() []string {
+	return updatedAtStamp(c.table, c.columns)
+}
+
+func updatedAtStamp(table string, columns []string) []string {
+	column, ok := stampedColumn(table, columns)
+	if !ok {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s = now()", column)}
+}
+
+// stampedColumn returns the updated_at column set automatically on table,
+// unless columns already sets it.
+func stampedColumn(table string, columns []string) (string, bool) {
+	column, ok := updatedAtColumns.Load(table)
+	if !ok || slices.Contains(columns, column.(string)) {
+		return "", false
+	}
+	return column.(string), true
+}
+
+// setClauses returns every SET assignment, including the automatic ones.
+func (c *Changeset) setClauses() string {
+	clauses := append(c.clauses(), c.stamp()...)
+	if c.version != nil {
+		clauses = append(clauses, fmt.Sprintf("%s = %s + 1", VersionColumn, VersionColumn))
+	}
+	return strings.Join(clauses, ", ")
+}
+
+// condition combines the where argument of Build with the conditions added
+// through Where. The version check is left out when versioned is false.
+func (c *Changeset) condition(versioned bool, where string, whereArgs ...any) Cond {
+	// where is kept for callers predating Where, both are combined with AND.
+	cond := c.where
+	if where != "" {
+		cond = And(Expr(where, whereArgs...), cond)
+	}
+	if versioned && c.version != nil {
+		cond = And(cond, Eq(VersionColumn, *c.version))
+	}
+	return cond
+}
+
+// Build returns the UPDATE statement. Audited changesets fail here, their
+// audit rows need the request context, so run them with Exec.
 func (c *Changeset) Build(where string, whereArgs ...any) (string, []any, error) {
-	if len(c.clauses) == 0 {
+	if c.err != nil {
+		return "", nil, c.err
+	}
+	if c.audit != "" {
+		return "", nil, fmt.Errorf("audited update on %s must be run with Changeset.Exec", c.table)
+	}
+	if len(c.columns) == 0 {
 		return "", nil, fmt.Errorf("no fields to update")
 	}
 
-	query := fmt.Sprintf("UPDATE %s SET %s",
-		c.table,
-		strings.Join(c.clauses, ", "),
-	)
+	query := fmt.Sprintf("UPDATE %s SET %s", c.table, c.setClauses())
 
-	if where != "" {
-		finalWhere := where
-		for i, arg := range whereArgs {
-			placeholder := fmt.Sprintf("$%d", c.Index+i)
-			finalWhere = strings.Replace(finalWhere, "?", placeholder, 1)
-			c.args = append(c.args, arg)
-		}
-
-		query += " WHERE " + finalWhere
+	clause, whereArgs, _, err := whereClause(c.condition(true, where, whereArgs...), c.Index)
+	if err != nil {
+		return "", nil, err
 	}
+	query += clause + returningClause(c.returning)
 
-	return query, c.args, nil
+	return query, append(slices.Clip(c.args), whereArgs...), nil
 }
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+type txKey struct{}
+
+// ContextWithTx returns a copy of ctx carrying tx, so a ContextDB used with
+// it joins the transaction.
+func ContextWithTx(ctx context.Context, tx Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction stored by ContextWithTx, if any.
+func TxFromContext(ctx context.Context) (Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(Tx)
+	return tx, ok
+}
+
+// ContextDB runs statements on the transaction found in the context and
+// falls back to the wrapped DB otherwise, letting functions that accept a
+// DB join their caller's transaction.
+type ContextDB struct {
+	db DB
+}
+
+func NewContextDB(db DB) *ContextDB {
+	return &ContextDB{db: db}
+}
+
+func (c *ContextDB) Query(ctx context.Context, sql string, args ...any) (Rows, error) {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.Query(ctx, sql, args...)
+	}
+	return c.db.Query(ctx, sql, args...)
+}
+
+func (c *ContextDB) QueryRow(ctx context.Context, sql string, args ...any) Row {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.QueryRow(ctx, sql, args...)
+	}
+	return c.db.QueryRow(ctx, sql, args...)
+}
+
+func (c *ContextDB) Exec(ctx context.Context, sql string, args ...any) (Result, error) {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.Exec(ctx, sql, args...)
+	}
+	return c.db.Exec(ctx, sql, args...)
+}
+
+// BeginTx opens a savepoint when the context already carries a transaction
+// and begins a new transaction on the wrapped DB otherwise.
+func (c *ContextDB) BeginTx(ctx context.Context, opts any) (Tx, error) {
+	tx, ok := TxFromContext(ctx)
+	if !ok {
+		return c.db.BeginTx(ctx, opts)
+	}
+	nested, ok := tx.(Beginner)
+	if !ok {
+		return nil, fmt.Errorf("transaction of type %T does not support savepoints", tx)
+	}
+	return nested.BeginTx(ctx, opts)
+}
+
+// InTx runs fn with a context carrying the transaction, through WithTx, so
+// every ContextDB call made with that context takes part in it.
+func (c *ContextDB) InTx(ctx context.Context, opts *TxOptions, fn func(ctx context.Context) error) error {
+	return WithTx(ctx, c, opts, func(tx Tx) error {
+		return fn(ContextWithTx(ctx, tx))
+	})
+}
+
+// bind resolves the Beginner WithTx should use, the context transaction when
+// there is one so nesting is detected and the retry is left to the outer call.
+func (c *ContextDB) bind(ctx context.Context) Beginner {
+	if tx, ok := TxFromContext(ctx); ok {
+		if nested, ok := tx.(Beginner); ok {
+			return nested
+		}
+	}
+	return c
+}
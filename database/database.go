@@ -2,16 +2,30 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/clineomx/trussrod/settings"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Postgres struct {
+	// Pool is the primary, taking writes, transactions and forced reads.
 	Pool *pgxpool.Pool
+
+	replicas  []*replica
+	tracer    *QueryTracer
+	next      atomic.Uint64
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 // pgxTxWrapper wraps pgx.Tx to implement the Tx interface.
@@ -27,6 +41,17 @@ func (w *pgxTxWrapper) Rollback(ctx context.Context) error {
 	return w.tx.Rollback(ctx)
 }
 
+// BeginTx starts a nested transaction backed by a SAVEPOINT, committing it
+// releases the savepoint and rolling it back only undoes the nested work.
+// Options cannot change inside a transaction and are ignored.
+func (w *pgxTxWrapper) BeginTx(ctx context.Context, _ any) (Tx, error) {
+	tx, err := w.tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pgxTxWrapper{tx: tx}, nil
+}
+
 func (w *pgxTxWrapper) Query(ctx context.Context, sql string, args ...any) (Rows, error) {
 	return w.tx.Query(ctx, sql, args...)
 }
@@ -53,7 +78,7 @@ func getURL(user, password, driver, host, port, name, sslmode, searchpath string
 	u := &url.URL{
 		Scheme: driver,
 		User:   userInfo,
-		Host:   fmt.Sprintf("%s:%s", host, port),
+		Host:   net.JoinHostPort(strings.Trim(host, "[]"), port),
 		Path:   name,
 	}
 
@@ -77,39 +102,160 @@ func getURL(user, password, driver, host, port, name, sslmode, searchpath string
 // sslmode: the ssl mode for the database
 // searchpath: the search path for the database
 // maxConns: the maximum number of connections to the database
+// replicas: read replicas, either DSNs or host:port pairs sharing the credentials above
+// with IPv6 hosts in brackets,
+// only used for contexts marked with ReadOnly
 // returns: a new Postgres client
 // error: if the database connection fails
-func NewPostgres(user, password, host, port, name, sslmode, searchpath string, maxConns int) (*Postgres, error) {
+func NewPostgres(user, password, host, port, name, sslmode, searchpath string, maxConns int, replicas ...string) (*Postgres, error) {
+	return newPostgres(&settings.DatabaseConfig{
+		Host:       host,
+		Port:       port,
+		Name:       name,
+		SSLMode:    sslmode,
+		User:       user,
+		Password:   password,
+		SearchPath: searchpath,
+		MaxConns:   maxConns,
+	}, replicas, nil)
+}
+
+// NewPostgresFromConfig creates a Postgres client from the database settings.
+// Connection, pool size and replicas come from cfg, opts adds the rest such
+// as a Tracer and may be nil.
+func NewPostgresFromConfig(cfg *settings.DatabaseConfig, opts *PostgresOptions) (*Postgres, error) {
+	return newPostgres(cfg, strings.Split(cfg.Replicas, ","), opts)
+}
+
+func newPostgres(cfg *settings.DatabaseConfig, replicas []string, opts *PostgresOptions) (*Postgres, error) {
 	const driver = "postgres"
-	var err error
-	cfg, err := pgxpool.ParseConfig(getURL(user, password, driver, host, port, name, sslmode, searchpath))
+	var options PostgresOptions
+	if opts != nil {
+		options = *opts
+	}
+	if options.MaxConns <= 0 {
+		options.MaxConns = cfg.MaxConns
+	}
+	dsns := slices.Clone(options.Replicas)
+	for _, replica := range replicas {
+		replica = strings.TrimSpace(replica)
+		if replica == "" {
+			continue
+		}
+		if !strings.Contains(replica, "://") {
+			replicaHost, replicaPort, err := splitReplica(replica, cfg.Port)
+			if err != nil {
+				return nil, err
+			}
+			replica = getURL(cfg.User, cfg.Password, driver, replicaHost, replicaPort, cfg.Name, cfg.SSLMode, cfg.SearchPath)
+		}
+		dsns = append(dsns, replica)
+	}
+	options.Replicas = dsns
+	primary := getURL(cfg.User, cfg.Password, driver, cfg.Host, cfg.Port, cfg.Name, cfg.SSLMode, cfg.SearchPath)
+	return NewPostgresWithOptions(primary, &options)
+}
+
+// splitReplica splits a host:port replica address, IPv6 hosts in brackets,
+// and uses port when the address has none.
+func splitReplica(replica, port string) (string, string, error) {
+	host, replicaPort, err := net.SplitHostPort(replica)
+	if err == nil {
+		return host, replicaPort, nil
+	}
+	var addrErr *net.AddrError
+	if !errors.As(err, &addrErr) || addrErr.Err != "missing port in address" {
+		return "", "", fmt.Errorf("invalid replica address %q: %w", replica, err)
+	}
+	return strings.Trim(replica, "[]"), port, nil
+}
+
+// NewPostgresFromDSN creates a Postgres client from a primary DSN and
+// optional replica DSNs. Replicas without a search_path inherit the primary's.
+func NewPostgresFromDSN(primary string, maxConns int, replicas ...string) (*Postgres, error) {
+	return NewPostgresWithOptions(primary, &PostgresOptions{
+		MaxConns: maxConns,
+		Replicas: replicas,
+	})
+}
+
+// PostgresOptions configures NewPostgresWithOptions.
+type PostgresOptions struct {
+	MaxConns int
+	// Replicas are DSNs of read replicas, used for contexts marked ReadOnly.
+	Replicas []string
+	// Tracer is installed on the primary and every replica.
+	Tracer *QueryTracer
+}
+
+func NewPostgresWithOptions(primary string, opts *PostgresOptions) (*Postgres, error) {
+	if opts == nil {
+		opts = &PostgresOptions{}
+	}
+	cfg, err := poolConfig(primary, opts)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	db := &Postgres{
+		Pool:   pool,
+		tracer: opts.Tracer,
+	}
+
+	for _, dsn := range opts.Replicas {
+		replicaCfg, err := poolConfig(dsn, opts)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		if replicaCfg.ConnConfig.RuntimeParams["options"] == "" && cfg.ConnConfig.RuntimeParams["options"] != "" {
+			replicaCfg.ConnConfig.RuntimeParams["options"] = cfg.ConnConfig.RuntimeParams["options"]
+		}
+		replicaPool, err := pgxpool.NewWithConfig(context.Background(), replicaCfg)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		db.replicas = append(db.replicas, &replica{pool: replicaPool})
+	}
+	if len(db.replicas) > 0 {
+		db.done = make(chan struct{})
+		go db.checkReplicas(replicaCheckInterval)
+	}
+
+	return db, nil
+}
+
+func poolConfig(dsn string, opts *PostgresOptions) (*pgxpool.Config, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, err
 	}
+	if opts.Tracer != nil {
+		cfg.ConnConfig.Tracer = opts.Tracer
+	}
 	cfg.MinConns = 0
-	if maxConns > 0 {
-		cfg.MaxConns = int32(maxConns)
+	if opts.MaxConns > 0 {
+		cfg.MaxConns = int32(opts.MaxConns)
 	} else {
 		cfg.MaxConns = 20
 	}
 	cfg.MaxConnLifetime = 10 * time.Minute
 	cfg.MaxConnIdleTime = 20 * time.Minute
-	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Postgres{
-		Pool: pool,
-	}, nil
+	return cfg, nil
 }
 
+// Query runs on a healthy replica when ctx is marked with ReadOnly and on
+// the primary otherwise.
 func (db *Postgres) Query(ctx context.Context, sql string, args ...any) (Rows, error) {
-	return db.Pool.Query(ctx, sql, args...)
+	return db.reader(ctx).Query(ctx, sql, args...)
 }
 
 func (db *Postgres) QueryRow(ctx context.Context, sql string, args ...any) Row {
-	return db.Pool.QueryRow(ctx, sql, args...)
+	return db.reader(ctx).QueryRow(ctx, sql, args...)
 }
 
 func (db *Postgres) Exec(ctx context.Context, sql string, args ...any) (Result, error) {
@@ -117,28 +263,37 @@ func (db *Postgres) Exec(ctx context.Context, sql string, args ...any) (Result,
 }
 
 func (db *Postgres) Close() {
+	db.closeOnce.Do(func() {
+		if db.done != nil {
+			close(db.done)
+		}
+		for _, r := range db.replicas {
+			r.pool.Close()
+		}
+	})
 	db.Pool.Close()
 }
 
+// QueryStats returns the per-fingerprint counters of the configured tracer,
+// or nil when tracing is off.
+func (db *Postgres) QueryStats() []QueryStats {
+	if db.tracer == nil {
+		return nil
+	}
+	return db.tracer.Stats()
+}
+
 func (db *Postgres) Ping(ctx context.Context) error {
 	return db.Pool.Ping(ctx)
 }
 
+// BeginTx begins a transaction, opts may be nil, TxOptions, pgx.TxOptions
+// or sql.TxOptions, by value or pointer.
 func (db *Postgres) BeginTx(ctx context.Context, opts any) (Tx, error) {
-
-	if opts == nil {
-		tx, err := db.Pool.BeginTx(ctx, pgx.TxOptions{})
-		if err != nil {
-			return nil, err
-		}
-		return &pgxTxWrapper{tx: tx}, nil
-	}
-
-	options, ok := opts.(pgx.TxOptions)
-	if !ok {
-		return nil, fmt.Errorf("invalid transaction options")
+	options, err := pgxOptions(opts)
+	if err != nil {
+		return nil, err
 	}
-
 	tx, err := db.Pool.BeginTx(ctx, options)
 	if err != nil {
 		return nil, err
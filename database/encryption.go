@@ -0,0 +1,160 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// sealedV1 marks values sealed with AES-GCM under the request DEK, with the
+// table, column and row ID as associated data.
+const sealedV1 byte = 1
+
+// ErrSealedValue is returned when a value is corrupt, has an unknown version
+// or was sealed for another key, table, column or row.
+var ErrSealedValue = errors.New("encrypted value cannot be opened")
+
+// Sealed is an encrypted column value as stored, scan into it and call Open
+// with the same table, column and row ID used by SetEncrypted.
+type Sealed []byte
+
+func (s *Sealed) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+	case []byte:
+		*s = append(Sealed(nil), v...)
+	default:
+		return fmt.Errorf("cannot scan %T into Sealed", src)
+	}
+	return nil
+}
+
+func (s Sealed) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return []byte(s), nil
+}
+
+// Open decrypts the value, a NULL column opens to nil.
+func (s Sealed) Open(dek []byte, table, column, rowID string) ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if len(s) == 0 || s[0] != sealedV1 {
+		return nil, fmt.Errorf("%w: unknown version", ErrSealedValue)
+	}
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	body := s[1:]
+	if len(body) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, fmt.Errorf("%w: too short", ErrSealedValue)
+	}
+	nonce, ciphertext := body[:gcm.NonceSize()], body[gcm.NonceSize():]
+	// Open into an empty slice so an empty value doesn't read back as NULL.
+	plaintext, err := gcm.Open([]byte{}, nonce, ciphertext, associatedData(sealedV1, table, column, rowID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSealedValue, err)
+	}
+	return plaintext, nil
+}
+
+// OpenString decrypts the value as a string, a NULL column opens to nil.
+func (s Sealed) OpenString(dek []byte, table, column, rowID string) (*string, error) {
+	plaintext, err := s.Open(dek, table, column, rowID)
+	if err != nil || plaintext == nil {
+		return nil, err
+	}
+	value := string(plaintext)
+	return &value, nil
+}
+
+// Seal encrypts plaintext for the given table, column and row, moving it
+// to another row or column makes Open fail.
+func Seal(dek, plaintext []byte, table, column, rowID string) (Sealed, error) {
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	sealed := make([]byte, 1+gcm.NonceSize(), 1+gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	sealed[0] = sealedV1
+	if _, err := rand.Read(sealed[1:]); err != nil {
+		return nil, err
+	}
+	nonce := sealed[1:]
+	return gcm.Seal(sealed, nonce, plaintext, associatedData(sealedV1, table, column, rowID)), nil
+}
+
+func newGCM(dek []byte) (cipher.AEAD, error) {
+	if len(dek) != 32 {
+		return nil, fmt.Errorf("invalid data key length %d, expected 32 bytes", len(dek))
+	}
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func associatedData(version byte, table, column, rowID string) []byte {
+	ad := make([]byte, 0, 3+len(table)+len(column)+len(rowID))
+	ad = append(ad, version)
+	ad = append(ad, table...)
+	ad = append(ad, 0)
+	ad = append(ad, column...)
+	ad = append(ad, 0)
+	return append(ad, rowID...)
+}
+
+// ForRow sets the row ID bound into values set with SetEncrypted.
+func (c *Changeset) ForRow(id string) *Changeset {
+	c.rowID = id
+	return c
+}
+
+// SetEncrypted seals value with dek, usually from request.GetDek, bound to
+// the table, column and the row given to ForRow. Value may be a string,
+// []byte or a pointer to either, nil sets NULL.
+func (c *Changeset) SetEncrypted(column string, value any, dek []byte) *Changeset {
+	var plaintext []byte
+	switch v := value.(type) {
+	case nil:
+		return c.SetNull(column)
+	case string:
+		plaintext = []byte(v)
+	case []byte:
+		if v == nil {
+			return c.SetNull(column)
+		}
+		plaintext = v
+	case *string:
+		if v == nil {
+			return c.SetNull(column)
+		}
+		plaintext = []byte(*v)
+	case *[]byte:
+		if v == nil || *v == nil {
+			return c.SetNull(column)
+		}
+		plaintext = *v
+	default:
+		c.err = fmt.Errorf("cannot encrypt %T for column %s", value, column)
+		return c
+	}
+	if c.rowID == "" {
+		c.err = fmt.Errorf("encrypting %s requires a row ID, call ForRow first", column)
+		return c
+	}
+	sealed, err := Seal(dek, plaintext, c.table, column, c.rowID)
+	if err != nil {
+		c.err = err
+		return c
+	}
+	return c.Set(column, []byte(sealed))
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var testDEK = bytes.Repeat([]byte{7}, 32)
+
+func mustSeal(t *testing.T, plaintext []byte) Sealed {
+	t.Helper()
+	sealed, err := Seal(testDEK, plaintext, "patients", "ssn", "42")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	return sealed
+}
+
+func TestSealedRoundTrip(t *testing.T) {
+	for _, plaintext := range [][]byte{[]byte("123-45-6789"), {}} {
+		sealed := mustSeal(t, plaintext)
+		if bytes.Contains(sealed, []byte("123-45-6789")) {
+			t.Fatal("sealed value holds the plaintext")
+		}
+		got, err := sealed.Open(testDEK, "patients", "ssn", "42")
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		if got == nil || !bytes.Equal(got, plaintext) {
+			t.Fatalf("Open = %#v, want %#v", got, plaintext)
+		}
+	}
+}
+
+func TestSealedOpenString(t *testing.T) {
+	got, err := mustSeal(t, []byte{}).OpenString(testDEK, "patients", "ssn", "42")
+	if err != nil {
+		t.Fatalf("OpenString: %v", err)
+	}
+	if got == nil || *got != "" {
+		t.Fatalf("OpenString = %v, want an empty string", got)
+	}
+
+	got, err = Sealed(nil).OpenString(testDEK, "patients", "ssn", "42")
+	if err != nil || got != nil {
+		t.Fatalf("OpenString of NULL = %v, %v, want nil", got, err)
+	}
+}
+
+func TestSealedOpenRejects(t *testing.T) {
+	sealed := mustSeal(t, []byte("123-45-6789"))
+	flipped := bytes.Clone(sealed)
+	flipped[len(flipped)-1] ^= 1
+	otherDEK := bytes.Repeat([]byte{8}, 32)
+
+	tests := []struct {
+		name               string
+		sealed             Sealed
+		dek                []byte
+		table, column, row string
+	}{
+		{"other table", sealed, testDEK, "doctors", "ssn", "42"},
+		{"other column", sealed, testDEK, "patients", "mrn", "42"},
+		{"other row", sealed, testDEK, "patients", "ssn", "43"},
+		{"shifted binding", sealed, testDEK, "patients", "ss", "n42"},
+		{"other key", sealed, otherDEK, "patients", "ssn", "42"},
+		{"flipped bit", flipped, testDEK, "patients", "ssn", "42"},
+		{"truncated", sealed[:len(sealed)-1], testDEK, "patients", "ssn", "42"},
+		{"too short", sealed[:10], testDEK, "patients", "ssn", "42"},
+		{"empty", Sealed{}, testDEK, "patients", "ssn", "42"},
+		{"unknown version", append(Sealed{2}, sealed[1:]...), testDEK, "patients", "ssn", "42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.sealed.Open(tt.dek, tt.table, tt.column, tt.row)
+			if !errors.Is(err, ErrSealedValue) {
+				t.Fatalf("Open error = %v, want ErrSealedValue", err)
+			}
+		})
+	}
+}
+
+func TestSealRejectsShortKey(t *testing.T) {
+	if _, err := Seal(testDEK[:16], []byte("x"), "patients", "ssn", "42"); err == nil {
+		t.Fatal("Seal succeeded with a 16 byte key, want an error")
+	}
+}
+
+func TestSetEncrypted(t *testing.T) {
+	empty := ""
+	query, args, err := NewChangeset("patients").
+		ForRow("42").
+		SetEncrypted("ssn", "123-45-6789", testDEK).
+		SetEncrypted("note", &empty, testDEK).
+		SetEncrypted("alias", (*string)(nil), testDEK).
+		Build("id = ?", "42")
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if want := "UPDATE patients SET ssn = $1, note = $2, alias = NULL WHERE id = $3"; query != want {
+		t.Fatalf("query = %q, want %q", query, want)
+	}
+	for i, want := range []string{"123-45-6789", ""} {
+		got, err := Sealed(args[i].([]byte)).Open(testDEK, "patients", []string{"ssn", "note"}[i], "42")
+		if err != nil || string(got) != want {
+			t.Fatalf("arg %d opens to %q, %v, want %q", i, got, err, want)
+		}
+	}
+
+	if _, _, err := NewChangeset("patients").SetEncrypted("ssn", "x", testDEK).Build("id = ?", "42"); err == nil {
+		t.Fatal("Build succeeded without ForRow, want an error")
+	}
+}
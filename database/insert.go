@@ -0,0 +1,170 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// onConflict renders an ON CONFLICT clause for inserts.
+type onConflict struct {
+	target  []string
+	update  []string
+	nothing bool
+}
+
+// render builds the clause, updating every inserted column outside the
+// conflict target when no explicit update columns were given.
+func (o *onConflict) render(inserted, extra []string) (string, error) {
+	if o == nil {
+		return "", nil
+	}
+	clause := " ON CONFLICT"
+	if len(o.target) > 0 {
+		clause += fmt.Sprintf(" (%s)", strings.Join(o.target, ", "))
+	}
+	if o.nothing {
+		return clause + " DO NOTHING", nil
+	}
+	if len(o.target) == 0 {
+		return "", fmt.Errorf("upsert requires a conflict target")
+	}
+
+	update := o.update
+	if len(update) == 0 {
+		for _, column := range inserted {
+			if !slices.Contains(o.target, column) {
+				update = append(update, column)
+			}
+		}
+	}
+	if len(update) == 0 {
+		return "", fmt.Errorf("no fields to update on conflict")
+	}
+	sets := make([]string, len(update))
+	for i, column := range update {
+		sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", column, column)
+	}
+	sets = append(sets, extra...)
+	return clause + " DO UPDATE SET " + strings.Join(sets, ", "), nil
+}
+
+func returningClause(columns []string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+	return " RETURNING " + strings.Join(columns, ", ")
+}
+
+// OnConflictDoUpdate turns BuildInsert into an upsert on target.
+// When no update columns are given every set column outside target is updated.
+func (c *Changeset) OnConflictDoUpdate(target []string, update ...string) *Changeset {
+	c.conflict = &onConflict{target: target, update: update}
+	return c
+}
+
+// OnConflictDoNothing makes BuildInsert skip rows conflicting on target,
+// an empty target skips on any conflict.
+func (c *Changeset) OnConflictDoNothing(target ...string) *Changeset {
+	c.conflict = &onConflict{target: target, nothing: true}
+	return c
+}
+
+// BuildInsert builds an INSERT of the set columns, sharing the placeholder
+// numbering of Set so nil-skipping setters work for inserts as well.
+func (c *Changeset) BuildInsert() (string, []any, error) {
+	if c.err != nil {
+		return "", nil, c.err
+	}
+	if len(c.columns) == 0 {
+		return "", nil, fmt.Errorf("no fields to insert")
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		c.table,
+		strings.Join(c.columns, ", "),
+		strings.Join(c.values, ", "),
+	)
+
+	conflict, err := c.conflict.render(c.columns, c.stamp())
+	if err != nil {
+		return "", nil, err
+	}
+	query += conflict + returningClause(c.returning)
+
+	return query, c.args, nil
+}
+
+// BulkInsert builds a multi-row INSERT over a fixed set of columns.
+type BulkInsert struct {
+	table     string
+	columns   []string
+	rows      []string
+	args      []any
+	returning []string
+	conflict  *onConflict
+	err       error
+	Index     int
+}
+
+func NewBulkInsert(table string, columns ...string) *BulkInsert {
+	return &BulkInsert{
+		table:   table,
+		columns: columns,
+		Index:   1,
+	}
+}
+
+// Row appends a row, values must follow the column order given to NewBulkInsert.
+func (b *BulkInsert) Row(values ...any) *BulkInsert {
+	if len(values) != len(b.columns) {
+		b.err = fmt.Errorf("row %d has %d values, expected %d", len(b.rows)+1, len(values), len(b.columns))
+		return b
+	}
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		placeholders[i] = fmt.Sprintf("$%d", b.Index)
+		b.args = append(b.args, value)
+		b.Index++
+	}
+	b.rows = append(b.rows, "("+strings.Join(placeholders, ", ")+")")
+	return b
+}
+
+func (b *BulkInsert) Returning(columns ...string) *BulkInsert {
+	b.returning = append(b.returning, columns...)
+	return b
+}
+
+func (b *BulkInsert) OnConflictDoUpdate(target []string, update ...string) *BulkInsert {
+	b.conflict = &onConflict{target: target, update: update}
+	return b
+}
+
+func (b *BulkInsert) OnConflictDoNothing(target ...string) *BulkInsert {
+	b.conflict = &onConflict{target: target, nothing: true}
+	return b
+}
+
+func (b *BulkInsert) Build() (string, []any, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if len(b.columns) == 0 || len(b.rows) == 0 {
+		return "", nil, fmt.Errorf("no rows to insert")
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+		b.table,
+		strings.Join(b.columns, ", "),
+		strings.Join(b.rows, ", "),
+	)
+
+	conflict, err := b.conflict.render(b.columns, updatedAtStamp(b.table, b.columns))
+	if err != nil {
+		return "", nil, err
+	}
+	query += conflict + returningClause(b.returning)
+
+	return query, b.args, nil
+}
@@ -60,3 +60,9 @@ type DB interface {
 	// BeginTx begins a new transaction.
 	BeginTx(ctx context.Context, opts any) (Tx, error)
 }
+
+// Beginner starts transactions. DB satisfies it, and so does the Tx returned
+// by Postgres, where BeginTx opens a savepoint.
+type Beginner interface {
+	BeginTx(ctx context.Context, opts any) (Tx, error)
+}
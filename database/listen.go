@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	listenBackoffBase = 100 * time.Millisecond
+	listenBackoffMax  = 30 * time.Second
+)
+
+// Notification is a message received on a LISTEN channel. Reconnected marks
+// the first delivery after the connection was re-established, notifications
+// sent while it was down are lost, so consumers should resync state.
+type Notification struct {
+	Channel     string
+	Payload     string
+	Reconnected bool
+}
+
+// Listen subscribes to channel on a dedicated primary connection and
+// delivers notifications until ctx is done, when the returned channel is
+// closed. Lost connections are re-established with backoff.
+func (db *Postgres) Listen(ctx context.Context, channel string) (<-chan Notification, error) {
+	conn, err := db.listen(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := make(chan Notification)
+	go func() {
+		defer close(notifications)
+		reconnected := false
+		for attempt := 0; ; {
+			if conn == nil {
+				if err := backoffListen(ctx, attempt); err != nil {
+					return
+				}
+				conn, err = db.listen(ctx, channel)
+				if err != nil {
+					attempt++
+					continue
+				}
+				attempt, reconnected = 0, true
+			}
+
+			n, err := conn.Conn().WaitForNotification(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					unlisten(conn)
+					return
+				}
+				// The connection is unusable, close it so the pool drops it.
+				conn.Conn().Close(context.Background())
+				conn.Release()
+				conn = nil
+				continue
+			}
+
+			select {
+			case notifications <- Notification{Channel: n.Channel, Payload: n.Payload, Reconnected: reconnected}:
+				reconnected = false
+			case <-ctx.Done():
+				unlisten(conn)
+				return
+			}
+		}
+	}()
+	return notifications, nil
+}
+
+func (db *Postgres) listen(ctx context.Context, channel string) (*pgxpool.Conn, error) {
+	conn, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// unlisten clears the subscription before handing the connection back.
+func unlisten(conn *pgxpool.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.Exec(ctx, "UNLISTEN *"); err != nil {
+		conn.Conn().Close(ctx)
+	}
+	conn.Release()
+}
+
+func backoffListen(ctx context.Context, attempt int) error {
+	delay := min(listenBackoffBase<<min(attempt, 16), listenBackoffMax)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notify sends payload on channel. Inside a transaction use
+// SELECT pg_notify($1, $2) on the Tx instead, it is delivered on commit.
+func (db *Postgres) Notify(ctx context.Context, channel, payload string) error {
+	_, err := db.Pool.Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
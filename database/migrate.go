@@ -0,0 +1,390 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const defaultMigrationsTable = "schema_migrations"
+
+// ErrMigrationDrift is returned when applied migrations no longer match the
+// files, either because a file changed or because it disappeared.
+var ErrMigrationDrift = errors.New("migrations drifted from the applied schema")
+
+// migrationFile matches names such as 0001_create_users.up.sql.
+var migrationFile = regexp.MustCompile(`^(\d+)_([\w-]+)\.(up|down)\.sql$`)
+
+type migration struct {
+	version  int64
+	name     string
+	up       string
+	down     string
+	checksum string
+}
+
+// MigrationStatus describes a migration file and whether it is applied.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	Drifted   bool
+}
+
+// Migrator applies versioned SQL files from a filesystem, usually an embed.FS,
+// recording each version and its checksum in a table. Every operation holds
+// a Postgres advisory lock, so concurrent deploys apply migrations once.
+type Migrator struct {
+	db         *Postgres
+	table      string
+	migrations []migration
+}
+
+// NewMigrator reads the migrations in dir. Files are named
+// <version>_<name>.up.sql with an optional matching .down.sql.
+func NewMigrator(db *Postgres, fsys fs.FS, dir string) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: match[2]}
+			byVersion[version] = m
+		}
+		if m.name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.name, match[2])
+		}
+		if match[3] == "up" {
+			sum := sha256.Sum256(content)
+			m.up = string(content)
+			m.checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.checksum == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b migration) int {
+		return cmp.Compare(a.version, b.version)
+	})
+
+	return &Migrator{
+		db:         db,
+		table:      defaultMigrationsTable,
+		migrations: migrations,
+	}, nil
+}
+
+// WithTable changes the table recording applied versions.
+func (m *Migrator) WithTable(table string) *Migrator {
+	m.table = table
+	return m
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+	return m.To(ctx, m.migrations[len(m.migrations)-1].version)
+}
+
+// Down reverts the last steps applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, func(conn *pgxpool.Conn, applied map[int64]appliedMigration) error {
+		if err := m.checkDrift(applied); err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			if _, ok := applied[m.migrations[i].version]; !ok {
+				continue
+			}
+			if err := m.revert(ctx, conn, m.migrations[i]); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// To migrates up or down until version is the latest applied migration,
+// zero reverts everything.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && !slices.ContainsFunc(m.migrations, func(mg migration) bool { return mg.version == version }) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.locked(ctx, func(conn *pgxpool.Conn, applied map[int64]appliedMigration) error {
+		if err := m.checkDrift(applied); err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mg := m.migrations[i]
+			if _, ok := applied[mg.version]; ok && mg.version > version {
+				if err := m.revert(ctx, conn, mg); err != nil {
+					return err
+				}
+			}
+		}
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.version]; !ok && mg.version <= version {
+				if err := m.apply(ctx, conn, mg); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Status lists every known migration with its applied time and drift.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var status []MigrationStatus
+	err := m.locked(ctx, func(_ *pgxpool.Conn, applied map[int64]appliedMigration) error {
+		for _, mg := range m.migrations {
+			s := MigrationStatus{Version: mg.version, Name: mg.name}
+			if a, ok := applied[mg.version]; ok {
+				s.AppliedAt = &a.appliedAt
+				s.Drifted = a.checksum != mg.checksum
+			}
+			status = append(status, s)
+		}
+		return nil
+	})
+	return status, err
+}
+
+// Run executes a migration command, meant to back a CLI subcommand:
+// "up", "down [steps]", "to <version>" or "status", which writes to w.
+func (m *Migrator) Run(ctx context.Context, w io.Writer, args ...string) error {
+	if len(args) == 0 {
+		return m.Up(ctx)
+	}
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		return m.Down(ctx, steps)
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("missing target version")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return m.To(ctx, version)
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Drifted {
+				state += " (drifted)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migration command %q", args[0])
+	}
+}
+
+// locked acquires a dedicated connection, takes the advisory lock, ensures
+// the migrations table exists and hands fn the applied versions.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgxpool.Conn, applied map[int64]appliedMigration) error) error {
+	conn, err := m.db.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", m.lockID()); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", m.lockID())
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, applied)
+}
+
+// lockID derives the advisory lock key from the schema and table, so
+// services sharing a database but not a schema don't block each other.
+func (m *Migrator) lockID() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(m.db.Pool.Config().ConnConfig.RuntimeParams["options"]))
+	h.Write([]byte(m.table))
+	return int64(h.Sum64())
+}
+
+// ensureTable creates the migrations table in the first schema of the
+// search_path, creating that schema when it doesn't exist yet.
+func (m *Migrator) ensureTable(ctx context.Context, conn *pgxpool.Conn) error {
+	var current *string
+	if err := conn.QueryRow(ctx, "SELECT current_schema()").Scan(&current); err != nil {
+		return err
+	}
+	if current == nil {
+		var searchPath string
+		if err := conn.QueryRow(ctx, "SHOW search_path").Scan(&searchPath); err != nil {
+			return err
+		}
+		schema := firstSchema(searchPath)
+		if schema == "" {
+			return fmt.Errorf("search_path %q names no schema to migrate", searchPath)
+		}
+		if _, err := conn.Exec(ctx, "CREATE SCHEMA IF NOT EXISTS "+pgx.Identifier{schema}.Sanitize()); err != nil {
+			return err
+		}
+	}
+
+	_, err := conn.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		checksum text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`, m.table))
+	return err
+}
+
+// firstSchema returns the first explicit schema of a search_path value.
+func firstSchema(searchPath string) string {
+	for _, schema := range strings.Split(searchPath, ",") {
+		schema = strings.Trim(strings.TrimSpace(schema), `"`)
+		if schema != "" && schema != "$user" {
+			return schema
+		}
+	}
+	return ""
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *pgxpool.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.Query(ctx, fmt.Sprintf("SELECT version, checksum, applied_at FROM %s", m.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]appliedMigration{}
+	for rows.Next() {
+		var version int64
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) checkDrift(applied map[int64]appliedMigration) error {
+	for version, a := range applied {
+		i := slices.IndexFunc(m.migrations, func(mg migration) bool { return mg.version == version })
+		if i < 0 {
+			return fmt.Errorf("%w: version %d is applied but has no file", ErrMigrationDrift, version)
+		}
+		if m.migrations[i].checksum != a.checksum {
+			return fmt.Errorf("%w: version %d_%s changed after it was applied", ErrMigrationDrift, version, m.migrations[i].name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *pgxpool.Conn, mg migration) error {
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, mg.up); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx,
+			fmt.Sprintf("INSERT INTO %s (version, name, checksum) VALUES ($1, $2, $3)", m.table),
+			mg.version, mg.name, mg.checksum,
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply migration %d_%s: %w", mg.version, mg.name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *pgxpool.Conn, mg migration) error {
+	if mg.down == "" {
+		return fmt.Errorf("migration %d_%s has no down file", mg.version, mg.name)
+	}
+	err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, mg.down); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE version = $1", m.table), mg.version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revert migration %d_%s: %w", mg.version, mg.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/clineomx/trussrod/events"
+	"github.com/clineomx/trussrod/logging"
+)
+
+// OutboxSchema creates the table used by Outbox, include it in a migration.
+const OutboxSchema = `CREATE TABLE IF NOT EXISTS outbox (
+	id bigserial PRIMARY KEY,
+	topic text NOT NULL,
+	payload jsonb NOT NULL,
+	attempts integer NOT NULL DEFAULT 0,
+	last_error text,
+	available_at timestamptz NOT NULL DEFAULT now(),
+	created_at timestamptz NOT NULL DEFAULT now(),
+	sent_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (available_at) WHERE sent_at IS NULL;`
+
+const (
+	defaultOutboxTable       = "outbox"
+	defaultOutboxBatchSize   = 100
+	defaultOutboxInterval    = time.Second
+	defaultOutboxMaxAttempts = 10
+	outboxMaxBackoff         = 5 * time.Minute
+)
+
+// OutboxOptions configures NewOutbox, zero values use the defaults.
+type OutboxOptions struct {
+	Table        string
+	BatchSize    int
+	PollInterval time.Duration
+	// MaxAttempts is how often publishing an event is tried before the relay
+	// leaves it for manual inspection.
+	MaxAttempts int
+}
+
+// Outbox stores events in the transaction producing them and relays them to
+// an EventQueue afterwards, so an event is published if and only if its
+// transaction commits. Delivery is at least once.
+type Outbox struct {
+	db          DB
+	queue       events.EventQueue
+	table       string
+	batchSize   int
+	interval    time.Duration
+	maxAttempts int
+}
+
+func NewOutbox(db DB, queue events.EventQueue, opts *OutboxOptions) *Outbox {
+	o := &Outbox{
+		db:          db,
+		queue:       queue,
+		table:       defaultOutboxTable,
+		batchSize:   defaultOutboxBatchSize,
+		interval:    defaultOutboxInterval,
+		maxAttempts: defaultOutboxMaxAttempts,
+	}
+	if opts == nil {
+		return o
+	}
+	if opts.Table != "" {
+		o.table = opts.Table
+	}
+	if opts.BatchSize > 0 {
+		o.batchSize = opts.BatchSize
+	}
+	if opts.PollInterval > 0 {
+		o.interval = opts.PollInterval
+	}
+	if opts.MaxAttempts > 0 {
+		o.maxAttempts = opts.MaxAttempts
+	}
+	return o
+}
+
+// Enqueue writes an event inside tx. The relay publishes payload with its
+// topic key set to topic, unless payload already has one.
+func (o *Outbox) Enqueue(ctx context.Context, tx Tx, topic events.Topic, payload map[string]any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx,
+		fmt.Sprintf("INSERT INTO %s (topic, payload) VALUES ($1, $2)", o.table),
+		string(topic), body,
+	)
+	return err
+}
+
+// Relay publishes pending events until ctx is done, polling every interval
+// and draining without waiting while full batches keep coming.
+func (o *Outbox) Relay(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		n, err := o.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("failed to relay outbox events", err)
+		}
+		if err == nil && n == o.batchSize {
+			timer.Reset(0)
+		} else {
+			timer.Reset(o.interval)
+		}
+	}
+}
+
+type outboxEvent struct {
+	id       int64
+	topic    string
+	payload  []byte
+	attempts int
+}
+
+// RelayOnce publishes one batch and returns how many events it handled.
+// Rows are claimed with SKIP LOCKED so several relays can run side by side,
+// failed events are retried later with exponential backoff.
+func (o *Outbox) RelayOnce(ctx context.Context) (int, error) {
+	var handled int
+	// Events are published before the commit, retrying the transaction would
+	// send the whole batch again.
+	err := WithTx(ctx, o.db, &TxOptions{MaxRetries: -1}, func(tx Tx) error {
+		pending, err := o.claim(ctx, tx)
+		if err != nil {
+			return err
+		}
+		handled = len(pending)
+
+		for _, event := range pending {
+			if err := o.publish(ctx, event); err != nil {
+				delay := min(time.Second<<min(event.attempts, 16), outboxMaxBackoff)
+				_, err = tx.Exec(ctx,
+					fmt.Sprintf("UPDATE %s SET attempts = attempts + 1, last_error = $2, available_at = now() + make_interval(secs => $3) WHERE id = $1", o.table),
+					event.id, err.Error(), delay.Seconds(),
+				)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			_, err := tx.Exec(ctx,
+				fmt.Sprintf("UPDATE %s SET attempts = attempts + 1, last_error = NULL, sent_at = now() WHERE id = $1", o.table),
+				event.id,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return handled, err
+}
+
+func (o *Outbox) claim(ctx context.Context, tx Tx) ([]outboxEvent, error) {
+	rows, err := tx.Query(ctx,
+		fmt.Sprintf(`SELECT id, topic, payload, attempts FROM %s
+		WHERE sent_at IS NULL AND available_at <= now() AND attempts < $1
+		ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED`, o.table),
+		o.maxAttempts, o.batchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []outboxEvent
+	for rows.Next() {
+		var event outboxEvent
+		if err := rows.Scan(&event.id, &event.topic, &event.payload, &event.attempts); err != nil {
+			return nil, err
+		}
+		pending = append(pending, event)
+	}
+	return pending, rows.Err()
+}
+
+func (o *Outbox) publish(ctx context.Context, event outboxEvent) error {
+	// Numbers stay json.Number so large IDs keep their precision.
+	var message map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(event.payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&message); err != nil {
+		return err
+	}
+	if message == nil {
+		message = map[string]any{}
+	}
+	if _, ok := message["topic"]; !ok {
+		message["topic"] = event.topic
+	}
+	return o.queue.Publish(ctx, message)
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bindPlaceholders rewrites each ? in expr into a numbered $n placeholder
+// starting at index and returns the next free index. Question marks inside
+// string literals and quoted identifiers are left alone, and ?? stands for
+// a literal ? so the JSONB operators stay usable.
+func bindPlaceholders(expr string, index, nargs int) (string, int, error) {
+	var b strings.Builder
+	b.Grow(len(expr))
+	bound := 0
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		switch ch {
+		case '\'', '"':
+			end := closingQuote(expr, i)
+			if end < 0 {
+				return "", index, fmt.Errorf("unterminated quote in %q", expr)
+			}
+			b.WriteString(expr[i : end+1])
+			i = end
+		case '?':
+			if i+1 < len(expr) && expr[i+1] == '?' {
+				b.WriteByte('?')
+				i++
+				continue
+			}
+			fmt.Fprintf(&b, "$%d", index)
+			index++
+			bound++
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	if bound != nargs {
+		return "", index, fmt.Errorf("expression %q has %d placeholders but %d arguments", expr, bound, nargs)
+	}
+	return b.String(), index, nil
+}
+
+// closingQuote returns the index of the quote closing the one at start,
+// treating a doubled quote as an escaped one.
+func closingQuote(s string, start int) int {
+	q := s[start]
+	for i := start + 1; i < len(s); i++ {
+		if s[i] != q {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == q {
+			i++
+			continue
+		}
+		return i
+	}
+	return -1
+}
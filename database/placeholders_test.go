@@ -0,0 +1,55 @@
+package database
+
+import "testing"
+
+func TestBindPlaceholders(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  string
+		index int
+		nargs int
+		want  string
+		next  int
+	}{
+		{"none", "deleted_at IS NULL", 1, 0, "deleted_at IS NULL", 1},
+		{"single", "id = ?", 1, 1, "id = $1", 2},
+		{"offset", "a = ? AND b = ?", 3, 2, "a = $3 AND b = $4", 5},
+		{"escaped", "tags ?? ?", 1, 1, "tags ? $1", 2},
+		{"escaped operators", "data ??| ? OR data ??& ?", 2, 2, "data ?| $2 OR data ?& $3", 4},
+		{"string literal", "note = 'why?' AND id = ?", 1, 1, "note = 'why?' AND id = $1", 2},
+		{"doubled quote", "note = 'it''s ?' AND id = ?", 1, 1, "note = 'it''s ?' AND id = $1", 2},
+		{"quoted identifier", `"odd?col" = ?`, 1, 1, `"odd?col" = $1`, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next, err := bindPlaceholders(tt.expr, tt.index, tt.nargs)
+			if err != nil {
+				t.Fatalf("bindPlaceholders(%q): %v", tt.expr, err)
+			}
+			if got != tt.want || next != tt.next {
+				t.Fatalf("bindPlaceholders(%q) = %q, %d, want %q, %d", tt.expr, got, next, tt.want, tt.next)
+			}
+		})
+	}
+}
+
+func TestBindPlaceholdersErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  string
+		nargs int
+	}{
+		{"too few args", "a = ? AND b = ?", 1},
+		{"too many args", "a = ?", 2},
+		{"escaped is not bound", "data ?? 'k'", 1},
+		{"unterminated string", "note = 'it''s ?", 1},
+		{"unterminated identifier", `"col = ?`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := bindPlaceholders(tt.expr, 1, tt.nargs); err == nil {
+				t.Fatalf("bindPlaceholders(%q, %d args) succeeded, want an error", tt.expr, tt.nargs)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/clineomx/trussrod/response"
+)
+
+// Cond is a WHERE condition whose ? placeholders are numbered when the
+// statement is built, so conditions compose without tracking indexes.
+type Cond struct {
+	expr string
+	args []any
+}
+
+// Expr creates a condition from raw SQL, each ? is bound to the matching arg.
+// Use ?? for a literal question mark such as the JSONB ? operator.
+func Expr(expr string, args ...any) Cond {
+	return Cond{expr: expr, args: args}
+}
+
+// Eq matches rows where column equals value.
+func Eq(column string, value any) Cond {
+	return Expr(column+" = ?", value)
+}
+
+// In matches rows where column is one of values, expanding one placeholder per value.
+// An empty list matches no rows.
+func In[T any](column string, values []T) Cond {
+	if len(values) == 0 {
+		return Expr("FALSE")
+	}
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	return Cond{expr: fmt.Sprintf("%s IN (%s)", column, marks), args: args}
+}
+
+func join(op string, conds []Cond) Cond {
+	var present []Cond
+	for _, c := range conds {
+		if c.expr != "" {
+			present = append(present, c)
+		}
+	}
+	if len(present) == 1 {
+		return present[0]
+	}
+	parts := make([]string, len(present))
+	var args []any
+	for i, c := range present {
+		parts[i] = "(" + c.expr + ")"
+		args = append(args, c.args...)
+	}
+	return Cond{expr: strings.Join(parts, " "+op+" "), args: args}
+}
+
+// And matches rows satisfying every condition.
+func And(conds ...Cond) Cond {
+	return join("AND", conds)
+}
+
+// Or matches rows satisfying any condition.
+func Or(conds ...Cond) Cond {
+	return join("OR", conds)
+}
+
+// Not negates a condition, an empty condition stays empty.
+func Not(c Cond) Cond {
+	if c.expr == "" {
+		return Cond{}
+	}
+	return Cond{expr: "NOT (" + c.expr + ")", args: c.args}
+}
+
+// whereClause renders cond starting at index and returns the clause, its
+// arguments and the next free index.
+func whereClause(cond Cond, index int) (string, []any, int, error) {
+	if cond.expr == "" {
+		return "", nil, index, nil
+	}
+	bound, next, err := bindPlaceholders(cond.expr, index, len(cond.args))
+	if err != nil {
+		return "", nil, index, err
+	}
+	return " WHERE " + bound, cond.args, next, nil
+}
+
+// orderClause parses a comma separated sort spec such as "-created_at,name"
+// where a leading minus sorts descending. Columns outside allowed are rejected,
+// which makes it safe to pass user input straight through.
+func orderClause(spec string, allowed []string) (string, error) {
+	var terms []string
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		direction := "ASC"
+		if strings.HasPrefix(field, "-") {
+			direction = "DESC"
+			field = field[1:]
+		}
+		if !slices.Contains(allowed, field) {
+			return "", fmt.Errorf("cannot order by %q", field)
+		}
+		terms = append(terms, field+" "+direction)
+	}
+	return strings.Join(terms, ", "), nil
+}
+
+// Select builds a SELECT statement.
+type Select struct {
+	table   string
+	columns []string
+	where   Cond
+	order   string
+	limit   int
+	offset  int
+	err     error
+}
+
+func NewSelect(table string, columns ...string) *Select {
+	return &Select{table: table, columns: columns}
+}
+
+// Where adds a condition, conditions added with Where or And are combined with AND.
+func (s *Select) Where(c Cond) *Select {
+	s.where = And(s.where, c)
+	return s
+}
+
+func (s *Select) And(c Cond) *Select {
+	return s.Where(c)
+}
+
+// Or combines every condition added so far with c using OR.
+func (s *Select) Or(c Cond) *Select {
+	s.where = Or(s.where, c)
+	return s
+}
+
+// OrderBy sorts by spec, see orderClause, accepting only allowed columns.
+func (s *Select) OrderBy(spec string, allowed ...string) *Select {
+	order, err := orderClause(spec, allowed)
+	if err != nil {
+		s.err = err
+		return s
+	}
+	s.order = order
+	return s
+}
+
+func (s *Select) Limit(limit int) *Select {
+	s.limit = limit
+	return s
+}
+
+func (s *Select) Offset(offset int) *Select {
+	s.offset = offset
+	return s
+}
+
+// Page applies the values returned by request.GetLimitAndOffset.
+func (s *Select) Page(limit, offset int) *Select {
+	return s.Limit(limit).Offset(offset)
+}
+
+func (s *Select) Build() (string, []any, error) {
+	if s.err != nil {
+		return "", nil, s.err
+	}
+	columns := "*"
+	if len(s.columns) > 0 {
+		columns = strings.Join(s.columns, ", ")
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", columns, s.table)
+
+	where, args, index, err := whereClause(s.where, 1)
+	if err != nil {
+		return "", nil, err
+	}
+	query += where
+	if s.order != "" {
+		query += " ORDER BY " + s.order
+	}
+	if s.limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d", index)
+		args = append(args, s.limit)
+		index++
+	}
+	if s.offset > 0 {
+		query += fmt.Sprintf(" OFFSET $%d", index)
+		args = append(args, s.offset)
+	}
+	return query, args, nil
+}
+
+// BuildCount builds a count(*) over the same conditions, without order or paging.
+func (s *Select) BuildCount() (string, []any, error) {
+	if s.err != nil {
+		return "", nil, s.err
+	}
+	where, args, _, err := whereClause(s.where, 1)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("SELECT count(*) FROM %s", s.table) + where, args, nil
+}
+
+// Paginate runs s and its count query and wraps the rows scanned by scan.
+func Paginate[T any](ctx context.Context, db DB, s *Select, scan func(row Row) (T, error)) (*response.Paginated[T], error) {
+	countQuery, countArgs, err := s.BuildCount()
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := s.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := db.QueryRow(ctx, countQuery, countArgs...).Scan(&count); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := 1
+	if s.limit > 0 {
+		page = s.offset/s.limit + 1
+	}
+	return &response.Paginated[T]{
+		Results: results,
+		Count:   count,
+		Page:    page,
+		Size:    s.limit,
+	}, nil
+}
+
+// Delete builds a DELETE statement.
+type Delete struct {
+	table     string
+	where     Cond
+	returning []string
+}
+
+func NewDelete(table string) *Delete {
+	return &Delete{table: table}
+}
+
+func (d *Delete) Where(c Cond) *Delete {
+	d.where = And(d.where, c)
+	return d
+}
+
+func (d *Delete) And(c Cond) *Delete {
+	return d.Where(c)
+}
+
+func (d *Delete) Or(c Cond) *Delete {
+	d.where = Or(d.where, c)
+	return d
+}
+
+func (d *Delete) Returning(columns ...string) *Delete {
+	d.returning = append(d.returning, columns...)
+	return d
+}
+
+// Build refuses to delete without a condition, pass Expr("TRUE") to clear a table.
+func (d *Delete) Build() (string, []any, error) {
+	if d.where.expr == "" {
+		return "", nil, fmt.Errorf("delete without a condition")
+	}
+	where, args, _, err := whereClause(d.where, 1)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("DELETE FROM %s", d.table) + where + returningClause(d.returning), args, nil
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+type builtQuery struct {
+	name  string
+	build func() (string, []any, error)
+	query string
+	args  []any
+}
+
+func checkBuilt(t *testing.T, tests []builtQuery) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := tt.build()
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			if query != tt.query {
+				t.Fatalf("query = %q, want %q", query, tt.query)
+			}
+			if len(args) != 0 || len(tt.args) != 0 {
+				if !reflect.DeepEqual(args, tt.args) {
+					t.Fatalf("args = %#v, want %#v", args, tt.args)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectBuild(t *testing.T) {
+	checkBuilt(t, []builtQuery{
+		{
+			name:  "all rows",
+			build: NewSelect("notes").Build,
+			query: "SELECT * FROM notes",
+		},
+		{
+			name: "and with paging",
+			build: NewSelect("notes", "id", "body").
+				Where(Eq("patient_id", 7)).
+				And(In("status", []string{"draft", "signed"})).
+				OrderBy("-created_at, id", "created_at", "id").
+				Page(10, 20).
+				Build,
+			query: "SELECT id, body FROM notes WHERE (patient_id = $1) AND (status IN ($2, $3)) ORDER BY created_at DESC, id ASC LIMIT $4 OFFSET $5",
+			args:  []any{7, "draft", "signed", 10, 20},
+		},
+		{
+			name:  "or",
+			build: NewSelect("notes").Where(Eq("a", 1)).Or(Eq("b", 2)).Build,
+			query: "SELECT * FROM notes WHERE (a = $1) OR (b = $2)",
+			args:  []any{1, 2},
+		},
+		{
+			name:  "empty in",
+			build: NewSelect("notes").Where(In("id", []int{})).Build,
+			query: "SELECT * FROM notes WHERE FALSE",
+		},
+		{
+			name:  "not",
+			build: NewSelect("notes").Where(Not(Eq("a", 1))).Build,
+			query: "SELECT * FROM notes WHERE NOT (a = $1)",
+			args:  []any{1},
+		},
+		{
+			name:  "not empty",
+			build: NewSelect("notes").Where(Not(Cond{})).Build,
+			query: "SELECT * FROM notes",
+		},
+		{
+			name:  "escaped and quoted marks",
+			build: NewSelect("notes").Where(Expr("data ?? ? AND note <> 'it''s ?'", "k")).Build,
+			query: "SELECT * FROM notes WHERE data ? $1 AND note <> 'it''s ?'",
+			args:  []any{"k"},
+		},
+		{
+			name:  "count ignores paging",
+			build: NewSelect("notes").Where(Eq("a", 1)).Page(10, 0).BuildCount,
+			query: "SELECT count(*) FROM notes WHERE a = $1",
+			args:  []any{1},
+		},
+	})
+}
+
+func TestSelectOrderByRejectsUnknownColumn(t *testing.T) {
+	if _, _, err := NewSelect("notes").OrderBy("password", "id").Build(); err == nil {
+		t.Fatal("Build succeeded, want an error for a column outside allowed")
+	}
+}
+
+func TestDeleteBuild(t *testing.T) {
+	checkBuilt(t, []builtQuery{
+		{
+			name:  "returning",
+			build: NewDelete("notes").Where(Eq("id", 1)).Returning("id").Build,
+			query: "DELETE FROM notes WHERE id = $1 RETURNING id",
+			args:  []any{1},
+		},
+		{
+			name:  "or",
+			build: NewDelete("notes").Where(Eq("a", 1)).Or(In("b", []int{2, 3})).Build,
+			query: "DELETE FROM notes WHERE (a = $1) OR (b IN ($2, $3))",
+			args:  []any{1, 2, 3},
+		},
+	})
+}
+
+func TestDeleteRequiresCondition(t *testing.T) {
+	for _, d := range []*Delete{NewDelete("notes"), NewDelete("notes").Where(Not(Cond{}))} {
+		if _, _, err := d.Build(); err == nil {
+			t.Fatal("Build succeeded, want an error without a condition")
+		}
+	}
+}
+
+func TestChangesetBuild(t *testing.T) {
+	checkBuilt(t, []builtQuery{
+		{
+			name: "legacy where",
+			build: func() (string, []any, error) {
+				return NewChangeset("notes").Set("body", "x").Set("signed", true).Build("id = ?", 5)
+			},
+			query: "UPDATE notes SET body = $1, signed = $2 WHERE id = $3",
+			args:  []any{"x", true, 5},
+		},
+		{
+			name: "legacy where with quoted mark",
+			build: func() (string, []any, error) {
+				return NewChangeset("notes").Set("body", "x").Build("note <> 'it''s ?' AND id = ?", 5)
+			},
+			query: "UPDATE notes SET body = $1 WHERE note <> 'it''s ?' AND id = $2",
+			args:  []any{"x", 5},
+		},
+		{
+			name: "legacy where and Where",
+			build: func() (string, []any, error) {
+				return NewChangeset("notes").Set("body", "x").Where(Eq("owner_id", 9)).Returning("id").Build("id = ?", 5)
+			},
+			query: "UPDATE notes SET body = $1 WHERE (id = $2) AND (owner_id = $3) RETURNING id",
+			args:  []any{"x", 5, 9},
+		},
+		{
+			name: "Where only",
+			build: func() (string, []any, error) {
+				return NewChangeset("notes").Set("body", "x").Where(In("id", []int{1, 2})).Build("")
+			},
+			query: "UPDATE notes SET body = $1 WHERE id IN ($2, $3)",
+			args:  []any{"x", 1, 2},
+		},
+		{
+			name: "expressions",
+			build: func() (string, []any, error) {
+				return NewChangeset("notes").SetExpr("views", "views + ?", 1).SetExpr("flagged", "data ?? ?", "k").SetNull("deleted_at").Build("id = ?", 5)
+			},
+			query: "UPDATE notes SET views = views + $1, flagged = data ? $2, deleted_at = NULL WHERE id = $3",
+			args:  []any{1, "k", 5},
+		},
+	})
+}
+
+func TestChangesetBuildErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		c    *Changeset
+	}{
+		{"no fields", NewChangeset("notes")},
+		{"expression args", NewChangeset("notes").SetExpr("views", "views + ?")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := tt.c.Build("id = ?", 1); err == nil {
+				t.Fatal("Build succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const replicaCheckInterval = 5 * time.Second
+
+type replica struct {
+	pool      *pgxpool.Pool
+	unhealthy atomic.Bool
+}
+
+type readOnlyKey struct{}
+
+type primaryKey struct{}
+
+// ReadOnly allows Query and QueryRow using ctx to run on a replica. Only
+// mark contexts whose statements have no side effects, since a SELECT can
+// still write through functions, locks or SELECT INTO.
+func ReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+// ForcePrimary makes reads using ctx go to the primary even when marked
+// ReadOnly, for reading back a write before the replicas caught up.
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func replicaAllowed(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyKey{}).(bool)
+	forced, _ := ctx.Value(primaryKey{}).(bool)
+	return readOnly && !forced
+}
+
+// reader picks the pool for a read. Only contexts marked ReadOnly go to
+// replicas, chosen round-robin among the healthy ones, everything else
+// stays on the primary, as do reads when no replica is healthy.
+func (db *Postgres) reader(ctx context.Context) *pgxpool.Pool {
+	if len(db.replicas) == 0 || !replicaAllowed(ctx) {
+		return db.Pool
+	}
+	start := db.next.Add(1)
+	for i := range uint64(len(db.replicas)) {
+		r := db.replicas[(start+i)%uint64(len(db.replicas))]
+		if !r.unhealthy.Load() {
+			return r.pool
+		}
+	}
+	return db.Pool
+}
+
+// checkReplicas pings every replica each interval, taking failing ones out
+// of rotation until they answer again.
+func (db *Postgres) checkReplicas(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-db.done:
+			return
+		case <-ticker.C:
+			for _, r := range db.replicas {
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				err := r.pool.Ping(ctx)
+				cancel()
+				r.unhealthy.Store(err != nil)
+			}
+		}
+	}
+}
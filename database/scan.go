@@ -0,0 +1,169 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/clineomx/trussrod/apperr"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ColumnRows is implemented by Rows that report their column names, which
+// ScanOne and ScanAll need to match columns to fields. pgx rows are
+// supported as well, fakes should implement Columns.
+type ColumnRows interface {
+	Rows
+	Columns() []string
+}
+
+// fieldPaths caches the db tag to field index mapping of each struct type.
+var fieldPaths sync.Map
+
+// ScanOne scans the first row into a T, mapping columns to fields by their
+// db tag, and closes rows. No rows results in apperr.NotFound.
+func ScanOne[T any](rows Rows) (T, error) {
+	defer rows.Close()
+
+	var item T
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return item, err
+		}
+		return item, apperr.NotFound()
+	}
+	dest, err := scanTargets(rows, reflect.ValueOf(&item).Elem())
+	if err != nil {
+		return item, err
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return item, err
+	}
+	return item, rows.Err()
+}
+
+// ScanAll scans every row into a T and closes rows, see ScanOne.
+// No rows results in an empty slice.
+func ScanAll[T any](rows Rows) ([]T, error) {
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		var item T
+		dest, err := scanTargets(rows, reflect.ValueOf(&item).Elem())
+		if err != nil {
+			return nil, err
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func columns(rows Rows) ([]string, error) {
+	switch r := rows.(type) {
+	case ColumnRows:
+		return r.Columns(), nil
+	case interface {
+		FieldDescriptions() []pgconn.FieldDescription
+	}:
+		fields := r.FieldDescriptions()
+		names := make([]string, len(fields))
+		for i, field := range fields {
+			names[i] = field.Name
+		}
+		return names, nil
+	default:
+		return nil, fmt.Errorf("rows of type %T do not report their columns", rows)
+	}
+}
+
+// scanTargets returns a pointer to the field of v matching each column,
+// allocating embedded struct pointers on the way.
+func scanTargets(rows Rows, v reflect.Value) ([]any, error) {
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot scan into %s, expected a struct", v.Type())
+	}
+	names, err := columns(rows)
+	if err != nil {
+		return nil, err
+	}
+	paths := structPaths(v.Type())
+
+	dest := make([]any, len(names))
+	for i, name := range names {
+		path, ok := paths[name]
+		if !ok {
+			return nil, fmt.Errorf("column %q has no matching field in %s", name, v.Type())
+		}
+		dest[i] = fieldByPath(v, path).Addr().Interface()
+	}
+	return dest, nil
+}
+
+func fieldByPath(v reflect.Value, path []int) reflect.Value {
+	for i, index := range path {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(index)
+	}
+	return v
+}
+
+// structPaths maps db tags to field index paths. Embedded structs without a
+// tag are flattened, with fields of the outer struct taking precedence, and
+// fields tagged "-" or without a tag are skipped.
+func structPaths(t reflect.Type) map[string][]int {
+	if paths, ok := fieldPaths.Load(t); ok {
+		return paths.(map[string][]int)
+	}
+	paths := map[string][]int{}
+	collectPaths(t, nil, paths)
+	fieldPaths.Store(t, paths)
+	return paths
+}
+
+func collectPaths(t reflect.Type, prefix []int, paths map[string][]int) {
+	var embedded []reflect.StructField
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag, tagged := field.Tag.Lookup("db")
+		if tag == "-" {
+			continue
+		}
+		if !tagged {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			// embedded pointers to unexported types cannot be allocated
+			settable := field.IsExported() || field.Type.Kind() != reflect.Pointer
+			if field.Anonymous && ft.Kind() == reflect.Struct && settable {
+				embedded = append(embedded, field)
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if _, ok := paths[tag]; !ok {
+			paths[tag] = append(append([]int{}, prefix...), i)
+		}
+	}
+	for _, field := range embedded {
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		collectPaths(ft, append(append([]int{}, prefix...), field.Index...), paths)
+	}
+}
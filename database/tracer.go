@@ -0,0 +1,261 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clineomx/trussrod/logging"
+	"github.com/clineomx/trussrod/request"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	placeholders = regexp.MustCompile(`\$\d+(?:\s*,\s*\$\d+)+`)
+	whitespace   = regexp.MustCompile(`\s+`)
+)
+
+// QueryStats aggregates the executions of one query fingerprint.
+type QueryStats struct {
+	Fingerprint string
+	Calls       uint64
+	Errors      uint64
+	Slow        uint64
+	Total       time.Duration
+	Max         time.Duration
+}
+
+// QueryTracer is a pgx tracer that logs queries slower than a threshold and
+// counts every query by fingerprint. Logged SQL never includes arguments,
+// and literals inlined in the text are replaced with ?.
+type QueryTracer struct {
+	threshold time.Duration
+	logger    *logging.Logger
+	mu        sync.Mutex
+	stats     map[string]*QueryStats
+}
+
+type traceKey struct{}
+
+type traceStart struct {
+	sql   string
+	nargs int
+	start time.Time
+}
+
+// NewQueryTracer creates a tracer logging queries taking at least threshold.
+// With a nil logger entries go to the request logger found in the context.
+func NewQueryTracer(threshold time.Duration, logger *logging.Logger) *QueryTracer {
+	return &QueryTracer{
+		threshold: threshold,
+		logger:    logger,
+		stats:     map[string]*QueryStats{},
+	}
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, traceKey{}, traceStart{
+		sql:   data.SQL,
+		nargs: len(data.Args),
+		start: time.Now(),
+	})
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	started, ok := ctx.Value(traceKey{}).(traceStart)
+	if !ok {
+		return
+	}
+	duration := time.Since(started.start)
+	fingerprint := Fingerprint(started.sql)
+	slow := t.threshold > 0 && duration >= t.threshold
+
+	t.record(fingerprint, duration, data.Err != nil, slow)
+	if !slow {
+		return
+	}
+
+	fields := map[string]any{
+		"sql":           redact(started.sql),
+		"args":          started.nargs,
+		"duration_ms":   duration.Milliseconds(),
+		"rows_affected": data.CommandTag.RowsAffected(),
+		"fingerprint":   fingerprint,
+	}
+	if traceID, ok := ctx.Value(request.ClineoTraceID).(string); ok {
+		fields["trace_id"] = traceID
+	}
+	if data.Err != nil {
+		// Postgres messages can quote the offending values, keep only the code.
+		var pgErr *pgconn.PgError
+		if errors.As(data.Err, &pgErr) {
+			fields["error_code"] = pgErr.Code
+		} else {
+			fields["error"] = data.Err.Error()
+		}
+	}
+	logger := t.logger
+	if logger == nil {
+		logger = logging.FromContext(ctx)
+	}
+	logger.WarnFields("slow query", fields)
+}
+
+func (t *QueryTracer) record(fingerprint string, duration time.Duration, failed, slow bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.stats[fingerprint]
+	if !ok {
+		s = &QueryStats{Fingerprint: fingerprint}
+		t.stats[fingerprint] = s
+	}
+	s.Calls++
+	s.Total += duration
+	s.Max = max(s.Max, duration)
+	if failed {
+		s.Errors++
+	}
+	if slow {
+		s.Slow++
+	}
+}
+
+// Stats returns a snapshot of the counters, slowest total time first.
+func (t *QueryTracer) Stats() []QueryStats {
+	t.mu.Lock()
+	stats := make([]QueryStats, 0, len(t.stats))
+	for _, s := range t.stats {
+		stats = append(stats, *s)
+	}
+	t.mu.Unlock()
+	slices.SortFunc(stats, func(a, b QueryStats) int {
+		return cmp.Compare(b.Total, a.Total)
+	})
+	return stats
+}
+
+// Reset clears the counters.
+func (t *QueryTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats = map[string]*QueryStats{}
+}
+
+// redact replaces literals inlined in sql with ?. It understands standard,
+// escape (E'...') and dollar quoted strings, quoted identifiers are kept.
+func redact(sql string) string {
+	var b strings.Builder
+	b.Grow(len(sql))
+	for i := 0; i < len(sql); {
+		ch := sql[i]
+		switch {
+		case (ch == 'E' || ch == 'e') && i+1 < len(sql) && sql[i+1] == '\'' && (i == 0 || !isWordByte(sql[i-1])):
+			b.WriteByte('?')
+			i = stringEnd(sql, i+1, true)
+		case ch == '\'':
+			b.WriteByte('?')
+			i = stringEnd(sql, i, false)
+		case ch == '"':
+			end := closingQuote(sql, i)
+			if end < 0 {
+				end = len(sql) - 1
+			}
+			b.WriteString(sql[i : end+1])
+			i = end + 1
+		case ch == '$':
+			j := i + 1
+			for j < len(sql) && isDigit(sql[j]) {
+				j++
+			}
+			if j > i+1 {
+				// A $n placeholder.
+				b.WriteString(sql[i:j])
+				i = j
+				continue
+			}
+			tag, ok := dollarTag(sql, i)
+			if !ok {
+				b.WriteByte(ch)
+				i++
+				continue
+			}
+			b.WriteByte('?')
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += len(tag) + end + len(tag)
+			}
+		case isDigit(ch) && (i == 0 || !isWordByte(sql[i-1])):
+			j := i
+			for j < len(sql) && isDigit(sql[j]) {
+				j++
+			}
+			if j+1 < len(sql) && sql[j] == '.' && isDigit(sql[j+1]) {
+				j++
+				for j < len(sql) && isDigit(sql[j]) {
+					j++
+				}
+			}
+			b.WriteByte('?')
+			i = j
+		default:
+			b.WriteByte(ch)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// stringEnd returns the index after the string literal starting at start,
+// or the end of sql when it is unterminated.
+func stringEnd(sql string, start int, escapes bool) int {
+	for i := start + 1; i < len(sql); i++ {
+		switch {
+		case escapes && sql[i] == '\\':
+			i++
+		case sql[i] == '\'':
+			if i+1 < len(sql) && sql[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// dollarTag returns the $tag$ opening a dollar quoted string at start.
+func dollarTag(sql string, start int) (string, bool) {
+	for i := start + 1; i < len(sql); i++ {
+		switch {
+		case sql[i] == '$':
+			return sql[start : i+1], true
+		case !isWordByte(sql[i]) || (i == start+1 && isDigit(sql[i])):
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isWordByte(ch byte) bool {
+	return ch == '_' || isDigit(ch) || (ch|0x20 >= 'a' && ch|0x20 <= 'z')
+}
+
+// Fingerprint normalizes sql so executions differing only in literals,
+// whitespace or the length of placeholder lists share counters.
+func Fingerprint(sql string) string {
+	sql = redact(sql)
+	sql = placeholders.ReplaceAllString(sql, "...")
+	return strings.TrimSpace(whitespace.ReplaceAllString(sql, " "))
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	defaultTxRetries = 3
+	txBackoffBase    = 20 * time.Millisecond
+	txBackoffMax     = time.Second
+)
+
+// TxOptions configures transactions started by BeginTx and WithTx.
+type TxOptions struct {
+	IsoLevel   pgx.TxIsoLevel
+	ReadOnly   bool
+	Deferrable bool
+	// MaxRetries caps how often WithTx retries serialization failures and
+	// deadlocks, zero means the default of 3 and a negative value disables it.
+	MaxRetries int
+}
+
+// pgxOptions converts the options accepted by BeginTx into pgx options.
+func pgxOptions(opts any) (pgx.TxOptions, error) {
+	switch o := opts.(type) {
+	case nil:
+		return pgx.TxOptions{}, nil
+	case pgx.TxOptions:
+		return o, nil
+	case *pgx.TxOptions:
+		if o == nil {
+			return pgx.TxOptions{}, nil
+		}
+		return *o, nil
+	case TxOptions:
+		return pgxOptions(&o)
+	case *TxOptions:
+		if o == nil {
+			return pgx.TxOptions{}, nil
+		}
+		options := pgx.TxOptions{IsoLevel: o.IsoLevel}
+		if o.ReadOnly {
+			options.AccessMode = pgx.ReadOnly
+		}
+		if o.Deferrable {
+			options.DeferrableMode = pgx.Deferrable
+		}
+		return options, nil
+	case sql.TxOptions:
+		return pgxOptions(&o)
+	case *sql.TxOptions:
+		if o == nil {
+			return pgx.TxOptions{}, nil
+		}
+		options := pgx.TxOptions{}
+		switch o.Isolation {
+		case sql.LevelDefault:
+		case sql.LevelReadUncommitted:
+			options.IsoLevel = pgx.ReadUncommitted
+		case sql.LevelReadCommitted:
+			options.IsoLevel = pgx.ReadCommitted
+		case sql.LevelRepeatableRead, sql.LevelSnapshot:
+			options.IsoLevel = pgx.RepeatableRead
+		case sql.LevelSerializable, sql.LevelLinearizable:
+			options.IsoLevel = pgx.Serializable
+		default:
+			return pgx.TxOptions{}, fmt.Errorf("unsupported isolation level %s", o.Isolation)
+		}
+		if o.ReadOnly {
+			options.AccessMode = pgx.ReadOnly
+		}
+		return options, nil
+	default:
+		return pgx.TxOptions{}, fmt.Errorf("invalid transaction options of type %T", opts)
+	}
+}
+
+// isRetryable reports whether err is a serialization failure or a deadlock.
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
+
+func backoff(ctx context.Context, attempt int) error {
+	delay := min(txBackoffBase<<attempt, txBackoffMax)
+	delay = delay/2 + rand.N(delay/2+1)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithTx runs fn inside a transaction begun on db, committing when fn
+// returns nil and rolling back when it errors or panics. Serialization
+// failures and deadlocks restart the whole transaction with backoff, so fn
+// must be safe to run more than once. When db is itself a Tx the work runs
+// in a SAVEPOINT instead, and retries are left to the outermost call. The
+// same holds for a ContextDB whose context already carries a transaction.
+func WithTx(ctx context.Context, db Beginner, opts *TxOptions, fn func(tx Tx) error) error {
+	retries := defaultTxRetries
+	if opts != nil && opts.MaxRetries != 0 {
+		retries = max(opts.MaxRetries, 0)
+	}
+	if c, ok := db.(*ContextDB); ok {
+		db = c.bind(ctx)
+	}
+	if _, nested := db.(Tx); nested {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := runTx(ctx, db, opts, fn)
+		if err == nil || attempt >= retries || !isRetryable(err) {
+			return err
+		}
+		if err := backoff(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+func runTx(ctx context.Context, db Beginner, opts *TxOptions, fn func(tx Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback(context.WithoutCancel(ctx))
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback(context.WithoutCancel(ctx))
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/clineomx/trussrod/apperr"
+	"github.com/jackc/pgx/v5"
+)
+
+// VersionColumn holds the row version used for optimistic concurrency.
+const VersionColumn = "version"
+
+// ExpectVersion only updates rows still at version n and increments it.
+// When Exec affects no rows it returns a conflict carrying the current
+// version in Fields, or not found when the row is gone.
+func (c *Changeset) ExpectVersion(n int64) *Changeset {
+	c.version = &n
+	return c
+}
+
+func (c *Changeset) versionConflict(ctx context.Context, db DB, where string, whereArgs ...any) error {
+	clause, args, _, err := whereClause(c.condition(false, where, whereArgs...), 1)
+	if err != nil {
+		return err
+	}
+	var current int64
+	query := fmt.Sprintf("SELECT %s FROM %s%s", VersionColumn, c.table, clause)
+	if err := db.QueryRow(ctx, query, args...).Scan(&current); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperr.NotFound()
+		}
+		return err
+	}
+	return apperr.ConflictWithCode(
+		"VERSION_CONFLICT",
+		"The resource was modified by someone else",
+		fmt.Sprintf("Expected version %d but the current version is %d", *c.version, current),
+	).WithFields(map[string]any{
+		"expected_version": *c.version,
+		"current_version":  current,
+	})
+}
@@ -23,6 +23,7 @@ require (
 	github.com/nyaruka/phonenumbers v1.6.5
 	github.com/redis/go-redis/v9 v9.13.0
 	github.com/twilio/twilio-go v1.29.0
+	golang.org/x/sync v0.17.0
 	golang.org/x/text v0.29.0
 )
 
@@ -53,7 +54,6 @@ require (
 	github.com/pkg/errors v0.9.1 // indirect
 	golang.org/x/crypto v0.42.0 // indirect
 	golang.org/x/exp v0.0.0-20250305212735-054e65f0b394 // indirect
-	golang.org/x/sync v0.17.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
 	google.golang.org/protobuf v1.36.5 // indirect
 )
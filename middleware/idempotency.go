@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/clineomx/trussrod/apperr"
+	"github.com/clineomx/trussrod/cache"
+	"github.com/clineomx/trussrod/logging"
+	"github.com/clineomx/trussrod/request"
+	"github.com/clineomx/trussrod/response"
+)
+
+// idempotencyLockTTL bounds how long a crashed replica can hold a key, the
+// lease is refreshed while the handler runs so slow handlers keep it.
+const idempotencyLockTTL = 30 * time.Second
+
+// storedResponse is what gets replayed for a repeated Idempotency-Key.
+type storedResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	StatusCode  int         `json:"status_code"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.RawQuery))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func loadResponse(r *http.Request, store cache.Client, key string) (*storedResponse, error) {
+	raw, err := store.Get(r.Context(), key)
+	if err != nil {
+		return nil, err
+	}
+	var stored storedResponse
+	if err := json.Unmarshal([]byte(raw), &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func replay(w http.ResponseWriter, stored *storedResponse, sum string) {
+	if stored.Fingerprint != sum {
+		response.WithError(w, apperr.ConflictWithCode(
+			"IDEMPOTENCY_KEY_REUSED",
+			"The idempotency key was already used with a different request",
+			"Use a new Idempotency-Key for a different payload",
+		))
+		return
+	}
+	// Headers set by outer middleware are already present, only the
+	// handler's own headers were stored and they replace any duplicates.
+	for k, values := range stored.Header {
+		w.Header()[k] = values
+	}
+	response.WithHeader(w, "Idempotent-Replayed", "true")
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.Body)
+}
+
+// keepAlive refreshes lease until the returned stop is called, so the lock
+// outlives handlers slower than idempotencyLockTTL.
+func keepAlive(ctx context.Context, lease cache.Lease, log *logging.Logger) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(idempotencyLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := lease.Refresh(ctx, idempotencyLockTTL); err != nil {
+					log.ErrorFields("failed to refresh idempotency lock", err, nil)
+					if errors.Is(err, cache.ErrLockLost) {
+						return
+					}
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// handlerHeader returns the headers changed since before, which are the
+// ones set by the handler rather than by outer middleware.
+func handlerHeader(before, after http.Header) http.Header {
+	changed := http.Header{}
+	for k, values := range after {
+		if !slices.Equal(before[k], values) {
+			changed[k] = slices.Clone(values)
+		}
+	}
+	return changed
+}
+
+// storable reports whether a response may be replayed. Server errors, rate
+// limits and failed authentication are transient, so clients retry them.
+func storable(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusTooManyRequests:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+// Idempotency replays the stored response of POST and PATCH requests
+// carrying an Idempotency-Key header that was already served within ttl.
+// While the first request runs, concurrent ones with the same key get a
+// 409, and reusing a key with a different payload is a 409 as well.
+// Server errors, 401 and 429 responses are not stored so clients can retry
+// them. If the cache is unavailable the request is served without
+// idempotency guarantees.
+func Idempotency(store cache.Client, locker cache.Locker, ttl time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost && r.Method != http.MethodPatch {
+				next.ServeHTTP(w, r)
+				return
+			}
+			idem, ok := request.GetHeader(r, request.IdempotencyKeyHeader)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body []byte
+			if r.Body != nil {
+				var err error
+				body, err = io.ReadAll(r.Body)
+				if err != nil {
+					response.WithError(w, err)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewBuffer(body))
+			}
+			sum := fingerprint(r, body)
+
+			// Keys are scoped per caller so users can't replay each other's
+			// responses, anonymous keys live apart so they can't name a user.
+			key := "idempotency:anon:" + idem
+			if user, ok := request.GetUser(r); ok {
+				key = "idempotency:user:" + user.ID + ":" + idem
+			}
+			log := logging.FromContext(r.Context())
+
+			stored, err := loadResponse(r, store, key)
+			if err == nil {
+				replay(w, stored, sum)
+				return
+			}
+			if !errors.Is(err, cache.ErrMiss) {
+				log.ErrorFields("idempotency store unavailable", err, nil)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			lease, err := locker.Acquire(r.Context(), key, idempotencyLockTTL)
+			if errors.Is(err, cache.ErrNotAcquired) {
+				response.WithError(w, apperr.ConflictWithCode(
+					"IDEMPOTENCY_KEY_IN_USE",
+					"A request with this idempotency key is still being processed",
+					"Retry once the original request completes",
+				))
+				return
+			}
+			if err != nil {
+				log.ErrorFields("idempotency lock unavailable", err, nil)
+				next.ServeHTTP(w, r)
+				return
+			}
+			// The handler may leave the request context cancelled, bookkeeping must still run.
+			ctx := context.WithoutCancel(r.Context())
+			defer func() {
+				if err := lease.Release(ctx); err != nil {
+					log.ErrorFields("failed to release idempotency lock", err, nil)
+				}
+			}()
+
+			// The first request may have finished while we were acquiring the lock.
+			if stored, err := loadResponse(r, store, key); err == nil {
+				replay(w, stored, sum)
+				return
+			}
+
+			before := w.Header().Clone()
+			wrapped := logging.NewResponseWriter(w)
+			// Deferred after Release so refreshing stops before the lock is freed.
+			defer keepAlive(ctx, lease, log)()
+			next.ServeHTTP(wrapped, r)
+
+			if !storable(wrapped.StatusCode) {
+				return
+			}
+			record := &storedResponse{
+				Fingerprint: sum,
+				StatusCode:  wrapped.StatusCode,
+				Header:      handlerHeader(before, w.Header()),
+				Body:        wrapped.Body.Bytes(),
+			}
+			if err := store.Set(ctx, key, record, ttl); err != nil {
+				log.ErrorFields("failed to store idempotent response", err, nil)
+			}
+		})
+	}
+}
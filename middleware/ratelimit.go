@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clineomx/trussrod/apperr"
+	"github.com/clineomx/trussrod/cache"
+	"github.com/clineomx/trussrod/logging"
+	"github.com/clineomx/trussrod/request"
+	"github.com/clineomx/trussrod/response"
+)
+
+// KeyFunc extracts the identity a request is rate limited by.
+type KeyFunc func(r *http.Request) (string, bool)
+
+// KeyByApiKey identifies callers by a hash of their API key header. The key
+// is not checked here, so only use it when every client has its own key and
+// an earlier middleware rejects unknown ones, otherwise clients can pick a
+// fresh bucket per request or share one bucket through a common key.
+func KeyByApiKey(r *http.Request) (string, bool) {
+	h, ok := request.GetHeader(r, request.ApiKeyHeader)
+	if !ok {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(h))
+	return "key:" + hex.EncodeToString(sum[:]), true
+}
+
+// KeyByUser identifies callers by the authenticated user ID.
+func KeyByUser(r *http.Request) (string, bool) {
+	user, ok := request.GetUser(r)
+	if !ok || user.ID == "" {
+		return "", false
+	}
+	return "user:" + user.ID, true
+}
+
+// KeyByIP identifies callers by the address of the connection. Behind a
+// load balancer use KeyByForwardedIP or KeyByHopIP instead.
+func KeyByIP(r *http.Request) (string, bool) {
+	addr, ok := remoteAddr(r)
+	if !ok {
+		return "", false
+	}
+	return "ip:" + addr.String(), true
+}
+
+// KeyByForwardedIP identifies callers by client IP behind the trusted
+// proxies. X-Forwarded-For is only read when the connection comes from a
+// trusted proxy, and is walked from the right skipping trusted addresses,
+// so entries a client prepends itself are never used.
+func KeyByForwardedIP(trusted ...netip.Prefix) KeyFunc {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, prefix := range trusted {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(r *http.Request) (string, bool) {
+		client, ok := remoteAddr(r)
+		if !ok {
+			return "", false
+		}
+		hops := forwardedFor(r)
+		for i := len(hops) - 1; i >= 0 && isTrusted(client); i-- {
+			addr, err := netip.ParseAddr(hops[i])
+			if err != nil {
+				break
+			}
+			client = addr.Unmap()
+		}
+		return "ip:" + client.String(), true
+	}
+}
+
+// KeyByHopIP identifies callers by client IP behind a fixed number of
+// proxies that each append to X-Forwarded-For, such as hops=1 behind a
+// single load balancer. Requests with fewer entries didn't pass through the
+// proxies and are keyed by the connection address.
+func KeyByHopIP(hops int) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		entries := forwardedFor(r)
+		if hops > 0 && len(entries) >= hops {
+			if addr, err := netip.ParseAddr(entries[len(entries)-hops]); err == nil {
+				return "ip:" + addr.Unmap().String(), true
+			}
+		}
+		return KeyByIP(r)
+	}
+}
+
+// ParseTrustedProxies parses CIDRs or single addresses for KeyByForwardedIP.
+func ParseTrustedProxies(values ...string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// forwardedFor returns every X-Forwarded-For entry in order, across
+// repeated headers.
+func forwardedFor(r *http.Request) []string {
+	var entries []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, entry := range strings.Split(header, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// RateLimit rejects requests exceeding rate with a 429.
+// scope separates the counters of different routes sharing a limiter.
+// The first KeyFunc that matches identifies the caller, when none are
+// given the connection address is used, see KeyByIP. Behind a load balancer
+// pass KeyByForwardedIP or KeyByHopIP so callers aren't keyed by the balancer.
+// If the limiter fails the request is let through.
+func RateLimit(limiter cache.RateLimiter, scope string, rate cache.Rate, keys ...KeyFunc) Middleware {
+	if len(keys) == 0 {
+		keys = []KeyFunc{KeyByIP}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var id string
+			for _, fn := range keys {
+				if k, ok := fn(r); ok {
+					id = k
+					break
+				}
+			}
+			if id == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := limiter.Allow(r.Context(), scope+":"+id, rate)
+			if err != nil {
+				logging.FromContext(r.Context()).ErrorFields("rate limiter unavailable", err, map[string]any{
+					"scope": scope,
+				})
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			response.WithHeader(w, "RateLimit-Limit", strconv.Itoa(result.Limit))
+			response.WithHeader(w, "RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			response.WithHeader(w, "RateLimit-Reset", seconds(result.ResetAfter))
+			if !result.Allowed {
+				response.WithHeader(w, "Retry-After", seconds(result.RetryAfter))
+				response.WithError(w, apperr.TooManyRequests(result.RetryAfter))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package middleware_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/clineomx/trussrod/middleware"
+)
+
+type forwardedCase struct {
+	name   string
+	remote string
+	xff    []string
+	want   string
+}
+
+func runKeyFunc(t *testing.T, fn middleware.KeyFunc, tests []forwardedCase) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, value := range tt.xff {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			got, ok := fn(r)
+			if !ok {
+				t.Fatalf("key not found, want %q", tt.want)
+			}
+			if got != tt.want {
+				t.Fatalf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyByForwardedIP(t *testing.T) {
+	trusted, err := middleware.ParseTrustedProxies("10.0.0.0/8", "192.168.1.1", "fd00::/8")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	runKeyFunc(t, middleware.KeyByForwardedIP(trusted...), []forwardedCase{
+		{"no header", "203.0.113.7:4000", nil, "ip:203.0.113.7"},
+		{"untrusted peer ignores header", "203.0.113.7:4000", []string{"198.51.100.1"}, "ip:203.0.113.7"},
+		{"single trusted proxy", "10.0.0.5:4000", []string{"198.51.100.1"}, "ip:198.51.100.1"},
+		{"spoofed left-most entry", "10.0.0.5:4000", []string{"1.1.1.1, 198.51.100.1"}, "ip:198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.5:4000", []string{"1.1.1.1, 198.51.100.1, 192.168.1.1, 10.1.2.3"}, "ip:198.51.100.1"},
+		{"repeated headers", "10.0.0.5:4000", []string{"1.1.1.1", "198.51.100.1"}, "ip:198.51.100.1"},
+		{"all entries trusted", "10.0.0.5:4000", []string{"10.0.0.9"}, "ip:10.0.0.9"},
+		{"unparsable hop stops at the proxy", "10.0.0.5:4000", []string{"1.1.1.1, bogus"}, "ip:10.0.0.5"},
+		{"unparsable hop past the client", "10.0.0.5:4000", []string{"bogus, 198.51.100.1"}, "ip:198.51.100.1"},
+		{"mapped IPv4 peer", "[::ffff:10.0.0.5]:4000", []string{"198.51.100.1"}, "ip:198.51.100.1"},
+		{"IPv6 proxy", "[fd00::1]:4000", []string{"2001:db8::1"}, "ip:2001:db8::1"},
+	})
+}
+
+func TestKeyByHopIP(t *testing.T) {
+	runKeyFunc(t, middleware.KeyByHopIP(1), []forwardedCase{
+		{"no header", "10.0.0.5:4000", nil, "ip:10.0.0.5"},
+		{"one hop", "10.0.0.5:4000", []string{"198.51.100.1"}, "ip:198.51.100.1"},
+		{"spoofed left-most entry", "10.0.0.5:4000", []string{"1.1.1.1, 198.51.100.1"}, "ip:198.51.100.1"},
+		{"unparsable hop", "10.0.0.5:4000", []string{"1.1.1.1, bogus"}, "ip:10.0.0.5"},
+	})
+	runKeyFunc(t, middleware.KeyByHopIP(2), []forwardedCase{
+		{"two hops", "10.0.0.5:4000", []string{"1.1.1.1, 198.51.100.1, 10.1.2.3"}, "ip:198.51.100.1"},
+		{"too few entries", "10.0.0.5:4000", []string{"198.51.100.1"}, "ip:10.0.0.5"},
+	})
+}
+
+func TestKeyByIPIgnoresForwardedFor(t *testing.T) {
+	runKeyFunc(t, middleware.KeyByIP, []forwardedCase{
+		{"spoofed header", "203.0.113.7:4000", []string{"1.1.1.1"}, "ip:203.0.113.7"},
+	})
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := middleware.ParseTrustedProxies(" 10.1.2.3/8 ", "", "::1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "::1/128"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("got %v, want %v", prefixes, want)
+	}
+	for i, prefix := range prefixes {
+		if prefix.String() != want[i] {
+			t.Fatalf("prefix %d = %s, want %s", i, prefix, want[i])
+		}
+	}
+	for _, bad := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0.1:80"} {
+		if _, err := middleware.ParseTrustedProxies(bad); err == nil {
+			t.Fatalf("ParseTrustedProxies(%q) succeeded, want an error", bad)
+		}
+	}
+}
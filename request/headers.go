@@ -7,9 +7,10 @@ import (
 type Header string
 
 const (
-	ApiKeyHeader   Header = "X-Clineo-Api-Key"
-	AuthHeader     Header = "Authorization"
-	IdentityHeader Header = "X-Clineo-Identity"
+	ApiKeyHeader         Header = "X-Clineo-Api-Key"
+	AuthHeader           Header = "Authorization"
+	IdentityHeader       Header = "X-Clineo-Identity"
+	IdempotencyKeyHeader Header = "Idempotency-Key"
 )
 
 func GetHeader(r *http.Request, h Header) (string, bool) {
@@ -24,6 +24,9 @@ type DatabaseConfig struct {
 	Password   string `json:"DB_PASSWORD"`
 	SearchPath string `json:"DB_SEARCHPATH"`
 	MaxConns   int    `json:"DB_MAX_CONNS"`
+	// Replicas is a comma separated list of read replicas, either full DSNs
+	// or host:port pairs sharing the primary's credentials.
+	Replicas string `json:"DB_REPLICAS"`
 }
 
 type CacheConfig struct {
@@ -32,6 +35,22 @@ type CacheConfig struct {
 	Port     string `json:"CACHE_PORT"`
 	User     string `json:"CACHE_USER"`
 	Password string `json:"CACHE_PASSWORD"`
+	DB       int    `json:"CACHE_DB"`
+	// Mode is one of standalone, sentinel or cluster, standalone by default.
+	Mode string `json:"CACHE_MODE"`
+	// Addrs is a comma separated host:port list of sentinels or cluster seeds.
+	Addrs            string `json:"CACHE_ADDRS"`
+	MasterName       string `json:"CACHE_MASTER_NAME"`
+	SentinelUser     string `json:"CACHE_SENTINEL_USER"`
+	SentinelPassword string `json:"CACHE_SENTINEL_PASSWORD"`
+	TLS              bool   `json:"CACHE_TLS"`
+	TLSServerName    string `json:"CACHE_TLS_SERVER_NAME"`
+	PoolSize         int    `json:"CACHE_POOL_SIZE"`
+	MinIdleConns     int    `json:"CACHE_MIN_IDLE_CONNS"`
+	// Timeouts are Go durations such as "500ms" or "5s".
+	DialTimeout  string `json:"CACHE_DIAL_TIMEOUT"`
+	ReadTimeout  string `json:"CACHE_READ_TIMEOUT"`
+	WriteTimeout string `json:"CACHE_WRITE_TIMEOUT"`
 }
 
 type OAuthConfig struct {
@@ -257,6 +276,7 @@ func getFromEnv(deps []string) *ClineoConfig {
 		dbconf.Password = os.Getenv("DB_PASSWORD")
 		dbconf.SearchPath = os.Getenv("DB_SEARCHPATH")
 		dbconf.MaxConns = maxConns
+		dbconf.Replicas = os.Getenv("DB_REPLICAS")
 		dbconf.SSLMode = os.Getenv("DB_SSLMODE")
 		if dbconf.SSLMode == "" {
 			dbconf.SSLMode = "disable"
@@ -270,6 +290,19 @@ func getFromEnv(deps []string) *ClineoConfig {
 		cacheconf.Port = os.Getenv("CACHE_PORT")
 		cacheconf.User = os.Getenv("CACHE_USER")
 		cacheconf.Password = os.Getenv("CACHE_PASSWORD")
+		cacheconf.DB, _ = strconv.Atoi(os.Getenv("CACHE_DB"))
+		cacheconf.Mode = os.Getenv("CACHE_MODE")
+		cacheconf.Addrs = os.Getenv("CACHE_ADDRS")
+		cacheconf.MasterName = os.Getenv("CACHE_MASTER_NAME")
+		cacheconf.SentinelUser = os.Getenv("CACHE_SENTINEL_USER")
+		cacheconf.SentinelPassword = os.Getenv("CACHE_SENTINEL_PASSWORD")
+		cacheconf.TLS, _ = strconv.ParseBool(os.Getenv("CACHE_TLS"))
+		cacheconf.TLSServerName = os.Getenv("CACHE_TLS_SERVER_NAME")
+		cacheconf.PoolSize, _ = strconv.Atoi(os.Getenv("CACHE_POOL_SIZE"))
+		cacheconf.MinIdleConns, _ = strconv.Atoi(os.Getenv("CACHE_MIN_IDLE_CONNS"))
+		cacheconf.DialTimeout = os.Getenv("CACHE_DIAL_TIMEOUT")
+		cacheconf.ReadTimeout = os.Getenv("CACHE_READ_TIMEOUT")
+		cacheconf.WriteTimeout = os.Getenv("CACHE_WRITE_TIMEOUT")
 	}
 
 	oauthconf := &OAuthConfig{}